@@ -0,0 +1,322 @@
+//Package apidiff compares the closed types of two versions of a package.
+//
+//Changes to closed types can break the users of a package
+//in ways that the type checker will not catch.
+//Adding a label to an enum or a member to a sum leaves
+//exhaustive switches over it incomplete,
+//while removing a label or changing its value breaks its users outright.
+package apidiff
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/jimmyfrasche/closed"
+)
+
+//Kind is the kind of a Change.
+type Kind int
+
+const (
+	//AddedType is a type that is only closed in the new version.
+	AddedType Kind = iota
+	//RemovedType is a type that is only closed in the old version.
+	RemovedType
+	//ChangedKind is a type that is a different kind of closed type in each version.
+	ChangedKind
+	//AddedLabel is a new label of an enum or flag of a bitset.
+	AddedLabel
+	//RemovedLabel is a label of an enum or flag of a bitset that no longer exists.
+	RemovedLabel
+	//ChangedValue is a label whose value changed.
+	ChangedValue
+	//AddedSynonym is a new name for an existing label.
+	AddedSynonym
+	//RemovedSynonym is a name that was removed from a label that still exists.
+	RemovedSynonym
+	//AddedMember is a new member of a sum.
+	AddedMember
+	//RemovedMember is a member of a sum that no longer exists.
+	RemovedMember
+	//ChangedNonZero is a change of Enum.NonZero.
+	ChangedNonZero
+	//ChangedOrdered is a change of Enum.Ordered.
+	ChangedOrdered
+	//ChangedNonNil is a change of Interface.NonNil or EmptySum.Nil.
+	ChangedNonNil
+	//ChangedFields is a change to the fields of an OptionalStruct.
+	ChangedFields
+)
+
+var kindNames = [...]string{
+	AddedType:      "added type",
+	RemovedType:    "removed type",
+	ChangedKind:    "changed kind",
+	AddedLabel:     "added label",
+	RemovedLabel:   "removed label",
+	ChangedValue:   "changed value",
+	AddedSynonym:   "added synonym",
+	RemovedSynonym: "removed synonym",
+	AddedMember:    "added member",
+	RemovedMember:  "removed member",
+	ChangedNonZero: "changed nonzero",
+	ChangedOrdered: "changed ordered",
+	ChangedNonNil:  "changed nonnil",
+	ChangedFields:  "changed fields",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown change"
+	}
+	return kindNames[k]
+}
+
+//A Change to a closed type between two versions of a package.
+type Change struct {
+	//Type is the name of the closed type.
+	Type string
+	Kind Kind
+	//Name is the label or member changed, if any.
+	Name string
+	//Old and New describe the value before and after the change, if any.
+	Old, New string
+	//Breaking is true if the change may break users of the package.
+	Breaking bool
+}
+
+func (c Change) String() string {
+	var b strings.Builder
+	b.WriteString(c.Type)
+	b.WriteString(": ")
+	b.WriteString(c.Kind.String())
+	if c.Name != "" {
+		b.WriteString(" ")
+		b.WriteString(c.Name)
+	}
+	if c.Old != "" || c.New != "" {
+		b.WriteString(": ")
+		b.WriteString(c.Old)
+		b.WriteString(" -> ")
+		b.WriteString(c.New)
+	}
+	return b.String()
+}
+
+//Changes returns the changes from the closed types of the old version of a package
+//to those of the new version, ordered by type name.
+//
+//Types are matched by the name of their defined type,
+//labels by name, and members by type.
+func Changes(old, new []closed.Type) []Change {
+	olds, news := byName(old), byName(new)
+	var d differ
+	for _, nm := range names(olds, news) {
+		o, n := olds[nm], news[nm]
+		switch {
+		case o == nil:
+			d.add(Change{Type: nm, Kind: AddedType, New: closed.KindOf(n).String()})
+		case n == nil:
+			d.add(Change{Type: nm, Kind: RemovedType, Old: closed.KindOf(o).String(), Breaking: true})
+		case closed.KindOf(o) != closed.KindOf(n):
+			d.add(Change{Type: nm, Kind: ChangedKind, Old: closed.KindOf(o).String(), New: closed.KindOf(n).String(), Breaking: true})
+		default:
+			d.diff(nm, o, n)
+		}
+	}
+	return d.changes
+}
+
+//Breaking reports whether any of cs are breaking.
+func Breaking(cs []Change) bool {
+	for _, c := range cs {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func byName(ts []closed.Type) map[string]closed.Type {
+	m := make(map[string]closed.Type, len(ts))
+	for _, t := range ts {
+		m[t.Types()[0].Name()] = t
+	}
+	return m
+}
+
+func names(a, b map[string]closed.Type) []string {
+	var acc []string
+	for nm := range a {
+		acc = append(acc, nm)
+	}
+	for nm := range b {
+		if _, ok := a[nm]; !ok {
+			acc = append(acc, nm)
+		}
+	}
+	sort.Strings(acc)
+	return acc
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(c Change) {
+	d.changes = append(d.changes, c)
+}
+
+//flag records a change of a boolean property from o to n,
+//which is breaking if it became true.
+func (d *differ) flag(nm string, k Kind, o, n bool) {
+	if o != n {
+		d.add(Change{Type: nm, Kind: k, Old: yes(o), New: yes(n), Breaking: n})
+	}
+}
+
+func yes(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func (d *differ) diff(nm string, o, n closed.Type) {
+	switch o := o.(type) {
+	case *closed.Enum:
+		n := n.(*closed.Enum)
+		d.flag(nm, ChangedNonZero, o.NonZero, n.NonZero)
+		//losing the order breaks users, gaining it does not
+		if o.Ordered != n.Ordered {
+			d.add(Change{Type: nm, Kind: ChangedOrdered, Old: yes(o.Ordered), New: yes(n.Ordered), Breaking: o.Ordered})
+		}
+		d.labels(nm, o.Labels, n.Labels, true)
+
+	case *closed.Bitset:
+		n := n.(*closed.Bitset)
+		d.labels(nm, o.Flags, n.Flags, true)
+		//the multibit flags are conveniences, so adding one is harmless
+		d.labels(nm, o.OrFlags, n.OrFlags, false)
+
+	case *closed.Interface:
+		n := n.(*closed.Interface)
+		d.flag(nm, ChangedNonNil, o.NonNil, n.NonNil)
+		d.members(nm, o.Types()[0].Pkg(), memberTypes(o.Members), n.Types()[0].Pkg(), memberTypes(n.Members))
+
+	case *closed.EmptySum:
+		n := n.(*closed.EmptySum)
+		d.flag(nm, ChangedNonNil, !o.Nil, !n.Nil)
+		d.members(nm, o.Types()[0].Pkg(), o.Members, n.Types()[0].Pkg(), n.Members)
+
+	case *closed.OptionalStruct:
+		n := n.(*closed.OptionalStruct)
+		of, nf := fields(o), fields(n)
+		if of != nf {
+			d.add(Change{Type: nm, Kind: ChangedFields, Old: of, New: nf, Breaking: true})
+		}
+	}
+}
+
+func fields(o *closed.OptionalStruct) string {
+	return o.Discriminant.Name() + ", " + o.Field.Name()
+}
+
+//label is a name of a label and the value it has.
+type label struct {
+	value constant.Value
+	//group is the index of the label in Labels.
+	group int
+}
+
+func labelsOf(css [][]*types.Const) map[string]label {
+	m := map[string]label{}
+	for i, cs := range css {
+		for _, c := range cs {
+			m[c.Name()] = label{c.Val(), i}
+		}
+	}
+	return m
+}
+
+//labels compares the labels of o and n.
+//Added labels are breaking if addBreaks.
+func (d *differ) labels(nm string, o, n [][]*types.Const, addBreaks bool) {
+	ol, nl := labelsOf(o), labelsOf(n)
+
+	//a name is a synonym if another name of its label is in the other version
+	sharesGroup := func(css [][]*types.Const, g int, other map[string]label) bool {
+		for _, c := range css[g] {
+			if _, ok := other[c.Name()]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, cs := range o {
+		for _, c := range cs {
+			L := ol[c.Name()]
+			N, ok := nl[c.Name()]
+			switch {
+			case ok:
+				if constant.Compare(L.value, token.NEQ, N.value) {
+					d.add(Change{Type: nm, Kind: ChangedValue, Name: c.Name(), Old: L.value.ExactString(), New: N.value.ExactString(), Breaking: true})
+				}
+			case sharesGroup(o, L.group, nl):
+				d.add(Change{Type: nm, Kind: RemovedSynonym, Name: c.Name(), Breaking: true})
+			default:
+				d.add(Change{Type: nm, Kind: RemovedLabel, Name: c.Name(), Breaking: true})
+			}
+		}
+	}
+
+	for _, cs := range n {
+		for _, c := range cs {
+			if _, ok := ol[c.Name()]; ok {
+				continue
+			}
+			if sharesGroup(n, nl[c.Name()].group, ol) {
+				d.add(Change{Type: nm, Kind: AddedSynonym, Name: c.Name()})
+			} else {
+				d.add(Change{Type: nm, Kind: AddedLabel, Name: c.Name(), Breaking: addBreaks})
+			}
+		}
+	}
+}
+
+func memberTypes(ms []*closed.TypeNamesAndType) []types.Type {
+	acc := make([]types.Type, len(ms))
+	for i, m := range ms {
+		acc[i] = m.Type
+	}
+	return acc
+}
+
+//members compares the members of a sum, written relative to the package of the sum.
+func (d *differ) members(nm string, opkg *types.Package, o []types.Type, npkg *types.Package, n []types.Type) {
+	str := func(pkg *types.Package, ts []types.Type) ([]string, map[string]bool) {
+		acc := make([]string, len(ts))
+		set := map[string]bool{}
+		for i, T := range ts {
+			acc[i] = types.TypeString(T, types.RelativeTo(pkg))
+			set[acc[i]] = true
+		}
+		return acc, set
+	}
+	oms, oset := str(opkg, o)
+	nms, nset := str(npkg, n)
+	for _, m := range oms {
+		if !nset[m] {
+			d.add(Change{Type: nm, Kind: RemovedMember, Name: m, Breaking: true})
+		}
+	}
+	for _, m := range nms {
+		if !oset[m] {
+			d.add(Change{Type: nm, Kind: AddedMember, Name: m, Breaking: true})
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package apidiff
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"github.com/jimmyfrasche/closed"
+)
+
+func closedTypes(t *testing.T, src string) []closed.Type {
+	t.Helper()
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, "p.go", "package p\n"+src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []*ast.File{f}
+	var cfg types.Config
+	pkg, err := cfg.Check("p", fs, files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := closed.InPackage(fs, files, pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+const enum = `
+type E int
+
+const (
+	A E = iota
+	B
+	C = B
+)
+`
+
+const iface = `
+type I interface {
+	Method()
+	isI()
+}
+
+type X struct{}
+
+func (X) Method() {}
+func (X) isI()    {}
+
+type Y struct{}
+
+func (*Y) Method() {}
+func (*Y) isI()    {}
+`
+
+func TestChanges(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		old, new string
+		want     []Change
+	}{
+		{
+			name: "same",
+			old:  enum,
+			new:  enum,
+		},
+		{
+			name: "added label",
+			old:  enum,
+			new:  enum + "const D E = 2\n",
+			want: []Change{
+				{Type: "E", Kind: AddedLabel, Name: "D", Breaking: true},
+			},
+		},
+		{
+			name: "replaced labels",
+			old:  enum,
+			new: `
+type E int
+
+const (
+	A E = iota
+	X E = 5
+)
+`,
+			want: []Change{
+				{Type: "E", Kind: RemovedLabel, Name: "B", Breaking: true},
+				{Type: "E", Kind: RemovedLabel, Name: "C", Breaking: true},
+				{Type: "E", Kind: AddedLabel, Name: "X", Breaking: true},
+			},
+		},
+		{
+			name: "synonyms",
+			old:  enum,
+			new: `
+type E int
+
+const (
+	A E = iota
+	B
+	D = B
+)
+`,
+			want: []Change{
+				{Type: "E", Kind: RemovedSynonym, Name: "C", Breaking: true},
+				{Type: "E", Kind: AddedSynonym, Name: "D"},
+			},
+		},
+		{
+			name: "changed value",
+			old:  enum,
+			new: `
+type E int
+
+const (
+	A E = iota + 1
+	B
+	C = B
+)
+`,
+			want: []Change{
+				{Type: "E", Kind: ChangedValue, Name: "A", Old: "0", New: "1", Breaking: true},
+				{Type: "E", Kind: ChangedValue, Name: "B", Old: "1", New: "2", Breaking: true},
+				{Type: "E", Kind: ChangedValue, Name: "C", Old: "1", New: "2", Breaking: true},
+			},
+		},
+		{
+			name: "nonzero",
+			old:  enum,
+			new:  "//closed:nonzero\n" + enum[1:],
+			want: []Change{
+				{Type: "E", Kind: ChangedNonZero, Old: "false", New: "true", Breaking: true},
+			},
+		},
+		{
+			name: "kind",
+			old:  enum,
+			new:  "type E interface{ M(); isE() }\n\ntype M struct{}\n\nfunc (M) M() {}\nfunc (M) isE() {}\n",
+			want: []Change{
+				{Type: "E", Kind: ChangedKind, Old: "enum", New: "interface", Breaking: true},
+			},
+		},
+		{
+			name: "members",
+			old:  iface,
+			new: iface + `
+type Z struct{}
+
+func (Z) Method() {}
+func (Z) isI()    {}
+`,
+			want: []Change{
+				{Type: "I", Kind: AddedMember, Name: "Z", Breaking: true},
+			},
+		},
+		{
+			name: "types",
+			old:  enum,
+			new:  iface,
+			want: []Change{
+				{Type: "E", Kind: RemovedType, Old: "enum", Breaking: true},
+				{Type: "I", Kind: AddedType, New: "interface"},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got := Changes(closedTypes(t, c.old), closedTypes(t, c.new))
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v\nwant %v", got, c.want)
+			}
+			if Breaking(got) != Breaking(c.want) {
+				t.Errorf("Breaking = %v", Breaking(got))
+			}
+		})
+	}
+}
@@ -6,13 +6,14 @@ import (
 	"sort"
 )
 
-func tokenIn(x token.Token, ys ...token.Token) bool {
-	for _, y := range ys {
-		if x == y {
-			return true
-		}
+//declaredBefore reports whether the declaration at a precedes the declaration at b,
+//ordering files by name.
+func declaredBefore(fs *token.FileSet, a, b token.Pos) bool {
+	pa, pb := fs.Position(a), fs.Position(b)
+	if pa.Filename != pb.Filename {
+		return pa.Filename < pb.Filename
 	}
-	return false
+	return pa.Offset < pb.Offset
 }
 
 type decl struct {
@@ -20,22 +21,16 @@ type decl struct {
 	decl       *ast.FuncDecl
 }
 
-//declsInFile returns a sorted index of relevant decls or the first BadDecl.
-func declsInFile(fs []*ast.File) (consts map[string]*ast.ValueSpec, decls []decl, err *ast.BadDecl) {
-	consts = map[string]*ast.ValueSpec{}
+//declsInFile returns the pattern of each package level constant, by the position of its name,
+//a sorted index of relevant decls, and any BadDecls, which are otherwise ignored.
+func declsInFile(fs []*ast.File) (consts map[token.Pos]declPattern, decls []decl, bad []*ast.BadDecl) {
+	consts = map[token.Pos]declPattern{}
 	for _, f := range fs {
 		for _, d := range f.Decls {
 			switch d := d.(type) {
 			case *ast.GenDecl:
-				if d.Tok != token.CONST {
-					continue
-				}
-
-				for _, spec := range d.Specs {
-					vs := spec.(*ast.ValueSpec)
-					for _, nm := range vs.Names {
-						consts[nm.Name] = vs
-					}
+				if d.Tok == token.CONST {
+					constPatterns(consts, d)
 				}
 
 			case *ast.FuncDecl:
@@ -46,7 +41,7 @@ func declsInFile(fs []*ast.File) (consts map[string]*ast.ValueSpec, decls []decl
 				})
 
 			case *ast.BadDecl:
-				return nil, nil, d
+				bad = append(bad, d)
 			}
 		}
 	}
@@ -54,20 +49,19 @@ func declsInFile(fs []*ast.File) (consts map[string]*ast.ValueSpec, decls []decl
 	sort.Slice(decls, func(i, j int) bool {
 		return decls[i].start < decls[j].start
 	})
-	return consts, decls, nil
+	return consts, decls, bad
 }
 
-//declsFor returns the ast.Decl containing pos.
+//declFor returns the ast.FuncDecl containing pos.
+//
+//Top level decls do not overlap, so the only candidate
+//is the last decl starting at or before pos.
 func declFor(decls []decl, pos token.Pos) *ast.FuncDecl {
-	//even for a very large package len(decls) is going to be small
-	//and on a well typed package a hit is guaranteed, and we're going
-	//to call this at most once per func/const,
-	//so linear search should be fine, but a better data structure
-	//for efficient range queries wouldn't hurt.
-	for _, d := range decls {
-		if d.start <= pos && pos <= d.end {
-			return d.decl
-		}
+	i := sort.Search(len(decls), func(i int) bool {
+		return decls[i].start > pos
+	}) - 1
+	if i < 0 || pos > decls[i].end {
+		return nil
 	}
-	return nil
+	return decls[i].decl
 }
@@ -0,0 +1,71 @@
+package closed
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"testing"
+)
+
+//linearDeclFor is the linear search declFor replaced.
+func linearDeclFor(decls []decl, pos token.Pos) *ast.FuncDecl {
+	for _, d := range decls {
+		if d.start <= pos && pos <= d.end {
+			return d.decl
+		}
+	}
+	return nil
+}
+
+//queries returns the positions of the names of every decl
+//and the start and end of every top level decl in l.
+func queries(l loaded) []token.Pos {
+	var ps []token.Pos
+	for _, f := range l.files {
+		for _, d := range f.Decls {
+			ps = append(ps, d.Pos(), d.End(), d.End()+1)
+			if fd, ok := d.(*ast.FuncDecl); ok {
+				ps = append(ps, fd.Name.Pos())
+			}
+		}
+	}
+	return ps
+}
+
+func TestDeclFor(t *testing.T) {
+	pkgs := append([]loaded{check(t, genAST(50))}, loadStd(t)...)
+	for _, l := range pkgs {
+		_, decls, bad := declsInFile(l.files)
+		if len(bad) > 0 {
+			t.Fatalf("%s: bad declaration", l.fs.Position(bad[0].Pos()))
+		}
+		for _, p := range queries(l) {
+			if got, want := declFor(decls, p), linearDeclFor(decls, p); got != want {
+				t.Errorf("%s: got %v, want %v", l.fs.Position(p), got, want)
+			}
+		}
+	}
+}
+
+func benchmarkDeclFor(b *testing.B, n int, find func([]decl, token.Pos) *ast.FuncDecl) {
+	l := check(b, genAST(n))
+	_, decls, _ := declsInFile(l.files)
+	ps := queries(l)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, p := range ps {
+			find(decls, p)
+		}
+	}
+}
+
+func BenchmarkDeclFor(b *testing.B) {
+	for _, n := range []int{100, 500, 2000} {
+		b.Run(fmt.Sprintf("search/%d", n), func(b *testing.B) {
+			benchmarkDeclFor(b, n, declFor)
+		})
+		b.Run(fmt.Sprintf("linear/%d", n), func(b *testing.B) {
+			benchmarkDeclFor(b, n, linearDeclFor)
+		})
+	}
+}
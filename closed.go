@@ -1,10 +1,13 @@
 package closed
 
 import (
-	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 )
 
 //InPackage extracts closed types from a given package.
@@ -17,19 +20,80 @@ import (
 //The files must be all the files used to parse pkg.
 //
 //The fs must be the FileSet used to parse pkg.
+//
+//The closed types are in the order that they are declared in pkg,
+//taking the files in order of their names.
+//The Members and FalseMembers of an Interface are in order of declaration, as well.
+//The Labels of an Enum and the Flags and OrFlags of a Bitset are in order of declaration
+//of the first label with each value,
+//and the synonyms for each value are in order of declaration.
+//
+//Declarations that cannot be understood do not prevent
+//the rest of the package from being considered.
+//If there are any, the closed types found regardless
+//are returned with an Errors describing the problems.
+//
+//InPackage uses the zero Config. Use a Config to tune the heuristics.
 func InPackage(fs *token.FileSet, files []*ast.File, pkg *types.Package) ([]Type, error) {
-	constDecls, funcDecls, bad := declsInFile(files)
-	if bad != nil {
-		return nil, fmt.Errorf("%s: bad declaration", fs.Position(bad.Pos()))
+	return NewSession(0).InPackage(fs, files, pkg)
+}
+
+//A Session extracts the closed types of many packages from one load,
+//sharing the method sets of the types they have in common.
+//
+//Nothing is retained between sessions,
+//so a long running process should create a Session for each load
+//and discard it, or bound it, to release the types it has seen.
+//
+//A Session is safe for concurrent use.
+type Session struct {
+	//Config tunes the heuristics used by the Session.
+	//If nil, the zero Config is used.
+	//It must not be changed once the Session is in use.
+	Config *Config
+
+	methodSets *methodSetCache
+}
+
+//NewSession creates a Session that remembers the method sets of at most limit types,
+//evicting the least recently used.
+//If limit <= 0, it remembers every method set computed.
+func NewSession(limit int) *Session {
+	return &Session{
+		methodSets: newMethodSetCache(limit),
+	}
+}
+
+//InPackage is InPackage using the method sets remembered by s.
+func (s *Session) InPackage(fs *token.FileSet, files []*ast.File, pkg *types.Package) ([]Type, error) {
+	ts, _, err := s.inPackage(fs, files, pkg, nil)
+	return ts, err
+}
+
+//Explain is InPackage, but also explains why each type defined in pkg
+//was or was not found to be closed, in order of declaration.
+func Explain(fs *token.FileSet, files []*ast.File, pkg *types.Package) ([]Type, []Explanation, error) {
+	return NewSession(0).Explain(fs, files, pkg)
+}
+
+//Explain is Explain using the method sets remembered by s.
+func (s *Session) Explain(fs *token.FileSet, files []*ast.File, pkg *types.Package) ([]Type, []Explanation, error) {
+	return s.inPackage(fs, files, pkg, newExplainer())
+}
+
+func (s *Session) inPackage(fs *token.FileSet, files []*ast.File, pkg *types.Package, why *explainer) ([]Type, []Explanation, error) {
+	var errs Errors
+	cfg := s.Config.withDefaults()
+
+	constDecls, funcDecls, bads := declsInFile(files)
+	for _, bad := range bads {
+		errs.add(fs, bad.Pos(), BadDecl, nil, "bad declaration")
 	}
 
-	consts, allTypes := extract(pkg.Scope())
+	consts, allTypes := extract(fs, pkg.Scope())
 	aliases, regTypes := findAliasesAndRegular(allTypes)
 
-	enums, bitsets, err := grabEnums(fs, constDecls, consts)
-	if err != nil {
-		return nil, err
-	}
+	enums, bitsets := grabEnums(why, &cfg, constDecls, consts)
 
 	out := pkgEnums(fs, aliases, enums)
 
@@ -39,28 +103,125 @@ func InPackage(fs *token.FileSet, files []*ast.File, pkg *types.Package) ([]Type
 
 	potOpts, _ := potentiallyClosedStructs(concrete)
 
-	if pkg.Path() == "database/sql" {
-		out = append(out, stdDatabaseSql(potOpts)...)
+	if pkg.Path() == "database/sql" && !cfg.NoSpecialCases {
+		std := stdDatabaseSql(potOpts)
+		why.all(std, "special case for the Null types of database/sql")
+		out = append(out, std...)
 	} //TODO define and parse comment
 
-	empties, closed := binInterfaces(abstract)
+	empties, closed := binInterfaces(s.methodSets, why, abstract)
 
-	if pkg.Path() == "encoding/xml" {
-		out = append(out, stdEncodingXml(empties, pkg.Scope())...)
-	} else if pkg.Path() == "encoding/json" {
-		out = append(out, stdEncodingJson(empties, pkg.Scope())...)
+	var std []Type
+	switch {
+	case cfg.NoSpecialCases:
+	case pkg.Path() == "encoding/xml":
+		std = stdEncodingXml(empties, pkg.Scope())
+	case pkg.Path() == "encoding/json":
+		std = stdEncodingJson(empties, pkg.Scope())
 	} //TODO define and parse comment - NB, have to enforce types in empty be imported into pkg
+	why.all(std, "special case for the Token type of %s", pkg.Path())
+	out = append(out, std...)
+	for _, e := range empties {
+		why.why(e, "empty interface, which is only closed for the Token types of encoding/json and encoding/xml")
+	}
 
-	sats := satisfiers(closed, concrete)
+	sats := satisfiers(s.methodSets, closed, concrete)
+	for _, i := range closed {
+		if len(sats[i]) == 0 {
+			why.why(i, "interface has unexported methods, but no types in the package satisfy it")
+		}
+	}
 
-	ifaces := pkgIfaces(aliases, funcDecls, sats)
+	ifaces := pkgIfaces(s.methodSets, aliases, funcDecls, sats)
+	for _, t := range ifaces {
+		t := t.(*Interface)
+		if len(t.TagMethods) > 0 {
+			why.why(t.Types()[0], "sum with tag methods %s and %d members in the package", strings.Join(t.TagMethods, ", "), len(t.Members))
+		} else {
+			why.why(t.Types()[0], "interface has unexported methods and %d members in the package", len(t.Members))
+		}
+	}
 	out = append(out, ifaces...)
 
-	return out, nil
+	applyDirectives(directivesOf(files), out)
+
+	kept := out[:0]
+	for _, t := range out {
+		if k := KindOf(t); cfg.Kinds&k == 0 {
+			why.exclude(t.Types()[0], k)
+			continue
+		}
+		kept = append(kept, t)
+	}
+	out = kept
+
+	sort.Slice(out, func(i, j int) bool {
+		return declaredBefore(fs, out[i].Types()[0].Pos(), out[j].Types()[0].Pos())
+	})
+	return out, why.explanations(regTypes, out), errs.err()
+}
+
+//A Package is a type checked package and the files used to check it.
+type Package struct {
+	Files []*ast.File
+	Types *types.Package
+}
+
+//A Result is the closed types of a Package
+//or the error that prevented extracting them.
+type Result struct {
+	Package *Package
+	Types   []Type
+	Err     error
+}
+
+//InPackages extracts the closed types of pkgs using at most workers goroutines,
+//or GOMAXPROCS goroutines if workers <= 0.
+//
+//The pkgs must all have been parsed with fs,
+//and they should have been checked with the same Importer,
+//so that their dependencies, and the method sets of the types in them,
+//are shared.
+//
+//The results are in the same order as pkgs,
+//regardless of the order that they are extracted.
+func (s *Session) InPackages(fs *token.FileSet, pkgs []*Package, workers int) []Result {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(pkgs) {
+		workers = len(pkgs)
+	}
+
+	out := make([]Result, len(pkgs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				p := pkgs[i]
+				ts, err := s.InPackage(fs, p.Files, p.Types)
+				out[i] = Result{
+					Package: p,
+					Types:   ts,
+					Err:     err,
+				}
+			}
+		}()
+	}
+	for i := range pkgs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return out
 }
 
-//extract what we care about from scope.
-func extract(s *types.Scope) (consts []*types.Const, allTypes []*types.TypeName) {
+//extract what we care about from scope, in order of declaration.
+func extract(fs *token.FileSet, s *types.Scope) (consts []*types.Const, allTypes []*types.TypeName) {
 	for _, nm := range s.Names() {
 		switch o := s.Lookup(nm).(type) {
 		case *types.Const:
@@ -71,5 +232,11 @@ func extract(s *types.Scope) (consts []*types.Const, allTypes []*types.TypeName)
 			//discard
 		}
 	}
+	sort.Slice(consts, func(i, j int) bool {
+		return declaredBefore(fs, consts[i].Pos(), consts[j].Pos())
+	})
+	sort.Slice(allTypes, func(i, j int) bool {
+		return declaredBefore(fs, allTypes[i].Pos(), allTypes[j].Pos())
+	})
 	return
 }
@@ -0,0 +1,217 @@
+package closed
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"testing"
+)
+
+func summary(ts []Type) map[string]int {
+	m := map[string]int{}
+	for _, t := range ts {
+		n := 0
+		switch t := t.(type) {
+		case *Enum:
+			n = len(t.Labels)
+		case *Bitset:
+			n = len(t.Flags)
+		case *Interface:
+			n = len(t.Members)
+		case *EmptySum:
+			n = len(t.Members)
+		}
+		m[t.Types()[0].Name()] = n
+	}
+	return m
+}
+
+func TestSession(t *testing.T) {
+	pkgs := loadStd(t)
+	for _, limit := range []int{0, 16} {
+		s := NewSession(limit)
+		for _, l := range pkgs {
+			want, err := InPackage(l.fs, l.files, l.pkg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.InPackage(l.fs, l.files, l.pkg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, w := summary(got), summary(want)
+			if len(g) != len(w) {
+				t.Fatalf("%s: session with limit %d found %d closed types, want %d", l.pkg.Path(), limit, len(g), len(w))
+			}
+			for nm, n := range w {
+				if g[nm] != n {
+					t.Errorf("%s.%s: session with limit %d found %d labels or members, want %d", l.pkg.Path(), nm, limit, g[nm], n)
+				}
+			}
+		}
+		if n := s.methodSets.len(); limit > 0 && n > limit {
+			t.Errorf("session with limit %d remembers %d method sets", limit, n)
+		}
+	}
+}
+
+func TestInPackages(t *testing.T) {
+	ls := loadStd(t)
+	pkgs := make([]*Package, len(ls))
+	for i, l := range ls {
+		pkgs[i] = &Package{l.files, l.pkg}
+	}
+	rs := NewSession(0).InPackages(ls[0].fs, pkgs, 4)
+	if len(rs) != len(pkgs) {
+		t.Fatalf("got %d results, want %d", len(rs), len(pkgs))
+	}
+	for i, r := range rs {
+		l := ls[i]
+		if r.Package != pkgs[i] {
+			t.Fatalf("result %d is for %s, want %s", i, r.Package.Types.Path(), l.pkg.Path())
+		}
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		want, err := InPackage(l.fs, l.files, l.pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, w := summary(r.Types), summary(want)
+		if len(g) != len(w) {
+			t.Fatalf("%s: found %d closed types, want %d", l.pkg.Path(), len(g), len(w))
+		}
+		for nm, n := range w {
+			if g[nm] != n {
+				t.Errorf("%s.%s: found %d labels or members, want %d", l.pkg.Path(), nm, g[nm], n)
+			}
+		}
+	}
+}
+
+//render ts so that results can be compared across runs.
+func render(tb testing.TB, ts []Type) string {
+	tb.Helper()
+	var ps []*Portable
+	for _, t := range ts {
+		p, err := ToPortable(t)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		ps = append(ps, p)
+	}
+	bs, err := json.Marshal(ps)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(bs)
+}
+
+func TestDeterministic(t *testing.T) {
+	pkgs := append([]loaded{check(t, genAST(50))}, loadStd(t)...)
+	for _, l := range pkgs {
+		want, err := NewSession(0).InPackage(l.fs, l.files, l.pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			got, err := NewSession(0).InPackage(l.fs, l.files, l.pkg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if render(t, got) != render(t, want) {
+				t.Fatalf("%s: run %d differs from the first", l.pkg.Path(), i)
+			}
+		}
+
+		for i := 1; i < len(want); i++ {
+			a, b := want[i-1].Types()[0], want[i].Types()[0]
+			if !declaredBefore(l.fs, a.Pos(), b.Pos()) {
+				t.Errorf("%s: %s is not declared before %s", l.pkg.Path(), a.Name(), b.Name())
+			}
+		}
+		for _, ct := range want {
+			var firsts []types.Object
+			switch ct := ct.(type) {
+			case *Interface:
+				for _, m := range ct.Members {
+					firsts = append(firsts, m.TypeName[0])
+				}
+			case *Enum:
+				for _, L := range ct.Labels {
+					firsts = append(firsts, L[0])
+				}
+			case *Bitset:
+				for _, L := range ct.Flags {
+					firsts = append(firsts, L[0])
+				}
+			}
+			for i := 1; i < len(firsts); i++ {
+				a, b := firsts[i-1], firsts[i]
+				if !declaredBefore(l.fs, a.Pos(), b.Pos()) {
+					t.Errorf("%s.%s: member %s is not declared before %s", l.pkg.Path(), ct.Types()[0].Name(), a.Name(), b.Name())
+				}
+			}
+		}
+	}
+
+	//declaration order, not alphabetical
+	ts, err := InPackage(pkgs[0].fs, pkgs[0].files, pkgs[0].pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := ts[0].(*Interface)
+	for i, m := range node.Members[:12] {
+		if got, want := m.TypeName[0].Name(), fmt.Sprintf("N%d", i); got != want {
+			t.Errorf("member %d of Node is %s, want %s", i, got, want)
+		}
+	}
+}
+
+func BenchmarkInPackage(b *testing.B) {
+	pkgs := loadStd(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, l := range pkgs {
+			if _, err := InPackage(l.fs, l.files, l.pkg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchmarkSession(b *testing.B, limit int) {
+	pkgs := loadStd(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := NewSession(limit)
+		for _, l := range pkgs {
+			if _, err := s.InPackage(l.fs, l.files, l.pkg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSession(b *testing.B) {
+	benchmarkSession(b, 0)
+}
+
+func BenchmarkSessionLimit(b *testing.B) {
+	benchmarkSession(b, 256)
+}
+
+func BenchmarkInPackages(b *testing.B) {
+	ls := loadStd(b)
+	pkgs := make([]*Package, len(ls))
+	for i, l := range ls {
+		pkgs[i] = &Package{l.files, l.pkg}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewSession(0).InPackages(ls[0].fs, pkgs, 0)
+	}
+}
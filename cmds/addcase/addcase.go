@@ -0,0 +1,206 @@
+//Command addcase adds cases for new labels of an enum or new members of a sum
+//to every switch over it in its package and its reverse dependencies.
+//
+//The new labels or members are named on the command line,
+//	addcase example.com/pkg Kind NewKind
+//or, with -rev, found by comparing the package to its version at a git revision,
+//	addcase -rev v1.2.0 example.com/pkg Kind
+//
+//Only switches without a default case are changed,
+//as those with a default case already handle the new values.
+//Cases are inserted as fillswitch would insert them,
+//with the body given by -body.
+//
+//The files that would change are printed.
+//With -w, they are also rewritten.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/jimmyfrasche/closed/apidiff"
+	"github.com/jimmyfrasche/closed/cmds/internal/fill"
+	"github.com/jimmyfrasche/closed/cmds/internal/tools"
+	"golang.org/x/tools/go/loader"
+)
+
+func failOn(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	log.SetPrefix(fmt.Sprintf("%s: ", os.Args[0]))
+	log.SetFlags(0)
+
+	tools.AddTagsFlagDefault()
+	var (
+		save         bool
+		rev          string
+		body, sortBy string
+	)
+	flag.BoolVar(&save, "w", false, "write the changed files")
+	flag.StringVar(&rev, "rev", "", "find the new labels or members by comparing to this git `revision`")
+	flag.StringVar(&body, "body", "", "Go `source` of the body of each new case")
+	flag.StringVar(&sortBy, "sort", "decl", "`order` of cases: decl, alpha, or value")
+
+	flag.Usage = func() {
+		log.SetPrefix("")
+		log.Printf("usage: %s [flags] importPath Type [label or member...]", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 || (rev == "") == (flag.NArg() == 2) {
+		log.Print("requires an import path, a type, and either -rev or the new labels or members")
+		flag.Usage()
+		os.Exit(2)
+	}
+	imp, typ := flag.Arg(0), flag.Arg(1)
+
+	mode, err := fill.ParseSortMode(sortBy)
+	failOn(err)
+	stmts, err := fill.ParseBody(body)
+	if err != nil {
+		failOn(fmt.Errorf("invalid -body: %s", err))
+	}
+
+	only := flag.Args()[2:]
+	if rev != "" {
+		only, err = added(rev, imp, typ)
+		failOn(err)
+		if len(only) == 0 {
+			log.Printf("no labels or members added to %s since %s", typ, rev)
+			return
+		}
+	}
+
+	prog, err := tools.LoadWithRdeps(imp)
+	failOn(err)
+
+	target, err := lookup(prog, imp, typ)
+	failOn(err)
+
+	opts := fill.Options{
+		Sort: mode,
+		Only: only,
+		Body: stmts,
+	}
+	for _, pkg := range prog.InitialPackages() {
+		for _, f := range pkg.Files {
+			src, err := addCases(prog, pkg, f, target, opts)
+			failOn(err)
+			if src == nil {
+				continue
+			}
+
+			name := prog.Fset.File(f.Pos()).Name()
+			fmt.Println(name)
+			if save {
+				failOn(ioutil.WriteFile(name, src, 0666))
+			}
+		}
+	}
+}
+
+//added returns the labels or members added to typ in imp since rev.
+func added(rev, imp, typ string) ([]string, error) {
+	bi, err := build.Import(imp, ".", build.FindOnly)
+	if err != nil {
+		return nil, err
+	}
+	old, cleanup, err := tools.Worktree(rev, bi.Dir)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	olds, err := tools.ClosedTypesInDir(old)
+	if err != nil {
+		return nil, err
+	}
+	news, err := tools.ClosedTypesInDir(bi.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var acc []string
+	for _, c := range apidiff.Changes(olds, news) {
+		if c.Type == typ && (c.Kind == apidiff.AddedLabel || c.Kind == apidiff.AddedMember) {
+			acc = append(acc, c.Name)
+		}
+	}
+	return acc, nil
+}
+
+func lookup(prog *loader.Program, imp, typ string) (*types.TypeName, error) {
+	pkg := prog.Package(imp)
+	if pkg == nil {
+		return nil, fmt.Errorf("could not load %q", imp)
+	}
+	t, ok := pkg.Pkg.Scope().Lookup(typ).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("no type %s in %q", typ, imp)
+	}
+	return t, nil
+}
+
+//addCases to the switches over target in f
+//and return the new source of f or nil if it is unchanged.
+func addCases(prog *loader.Program, pkg *loader.PackageInfo, f *ast.File, target *types.TypeName, opts fill.Options) ([]byte, error) {
+	var sws []*fill.Switch
+	for _, sw := range fill.All(prog, pkg, f, nil) {
+		if sw.Closed.Types()[0] == target && !hasDefault(sw.Stmt) {
+			sws = append(sws, sw)
+		}
+	}
+	if len(sws) == 0 {
+		return nil, nil
+	}
+
+	var before bytes.Buffer
+	if err := format.Node(&before, prog.Fset, f); err != nil {
+		return nil, err
+	}
+	for _, sw := range sws {
+		if err := sw.Fill(opts); err != nil {
+			return nil, err
+		}
+	}
+	var after bytes.Buffer
+	if err := format.Node(&after, prog.Fset, f); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(before.Bytes(), after.Bytes()) {
+		return nil, nil
+	}
+	return after.Bytes(), nil
+}
+
+func hasDefault(s ast.Stmt) bool {
+	var body *ast.BlockStmt
+	switch s := s.(type) {
+	case *ast.SwitchStmt:
+		body = s.Body
+	case *ast.TypeSwitchStmt:
+		body = s.Body
+	default:
+		return false
+	}
+	for _, c := range body.List {
+		if c.(*ast.CaseClause).List == nil {
+			return true
+		}
+	}
+	return false
+}
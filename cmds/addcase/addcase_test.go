@@ -0,0 +1,147 @@
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/fill"
+	"golang.org/x/tools/go/loader"
+)
+
+//load the packages e and u in testdata
+//and return u and its file named file.
+func load(t *testing.T, file string) (*loader.Program, *loader.PackageInfo, *ast.File) {
+	t.Helper()
+	gopath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GO111MODULE", "off")
+	bc := build.Default
+	bc.GOPATH = gopath
+
+	cfg := &loader.Config{
+		ParserMode: parser.ParseComments,
+		Build:      &bc,
+	}
+	cfg.Import("e")
+	cfg.Import("u")
+	prog, err := cfg.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("u")
+	for _, f := range pkg.Files {
+		if filepath.Base(prog.Fset.File(f.Pos()).Name()) == file {
+			return prog, pkg, f
+		}
+	}
+	t.Fatalf("no file %s in u", file)
+	return nil, nil, nil
+}
+
+func body(t *testing.T, src string) []ast.Stmt {
+	t.Helper()
+	stmts, err := fill.ParseBody(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stmts
+}
+
+func TestAddCases(t *testing.T) {
+	tests := []struct {
+		name, typ string
+		opts      fill.Options
+	}{
+		{"labels", "Kind", fill.Options{Only: []string{"C", "D"}}},
+		{"body", "Kind", fill.Options{Only: []string{"C", "D"}, Body: body(t, "n := 1\nif n > 0 {\n\tpanic(\"new\")\n}")}},
+		{"member", "Sum", fill.Options{Only: []string{"*Q"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, pkg, f := load(t, "u.go")
+			target, err := lookup(prog, "e", tc.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := addCases(prog, pkg, f, target, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", tc.name+".golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestAddCasesDefault(t *testing.T) {
+	prog, pkg, f := load(t, "default.go")
+	target, err := lookup(prog, "e", "Kind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := addCases(prog, pkg, f, target, fill.Options{Only: []string{"C"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("switch with a default case changed:\n%s", got)
+	}
+}
+
+func TestAdded(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	gopath := t.TempDir()
+	dir := filepath.Join(gopath, "src", "e")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(src string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "e.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s\n%s", args, err, out)
+		}
+	}
+
+	write("package e\n\ntype Kind int\n\nconst (\n\tA Kind = iota\n\tB\n)\n")
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+	write("package e\n\ntype Kind int\n\nconst (\n\tA Kind = iota\n\tB\n\tC\n\tD\n)\n")
+
+	t.Setenv("GO111MODULE", "off")
+	defer func(old string) {
+		build.Default.GOPATH = old
+	}(build.Default.GOPATH)
+	build.Default.GOPATH = gopath
+
+	got, err := added("HEAD", "e", "Kind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"C", "D"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
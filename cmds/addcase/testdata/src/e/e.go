@@ -0,0 +1,22 @@
+package e
+
+type Kind int
+
+const (
+	A Kind = iota
+	B
+	C
+	D
+)
+
+type Sum interface {
+	isSum()
+}
+
+type P struct{}
+
+func (P) isSum() {}
+
+type Q struct{}
+
+func (*Q) isSum() {}
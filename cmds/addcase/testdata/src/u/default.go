@@ -0,0 +1,10 @@
+package u
+
+import "e"
+
+func kindDefault(k e.Kind) {
+	switch k {
+	case e.A:
+	default:
+	}
+}
@@ -0,0 +1,16 @@
+package u
+
+import "e"
+
+func kind(k e.Kind) {
+	switch k {
+	case e.A:
+	case e.B:
+	}
+}
+
+func sum(s e.Sum) {
+	switch s.(type) {
+	case e.P:
+	}
+}
@@ -0,0 +1,68 @@
+//Command cllint reports misuses of closed types.
+//
+//It runs the analyzers for closed types over the packages named on the command line.
+//For the flags controlling the analyzers, run
+//	cllint help
+//
+//With -ssa, the exhaustive analyzer is replaced by exhaustivessa,
+//which builds the SSA form of each package
+//to only require the cases for the values that can reach each switch.
+package main
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/bitops"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedconv"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/enumarith"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/exhaustive"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/impossible"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/optional"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/zerovalue"
+	"golang.org/x/tools/go/analysis/multichecker"
+)
+
+//The analyzers are fixed before multichecker parses the flags,
+//so -ssa is defined only for the parse to accept it and is read by useSSA.
+var _ = flag.Bool("ssa", false, "replace exhaustive with exhaustivessa")
+
+//useSSA reports whether args, up to any --, set -ssa.
+func useSSA(args []string) bool {
+	on := false
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			continue
+		}
+		name, val, hasVal := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(a, "-"), "-"), "=")
+		if name != "ssa" {
+			continue
+		}
+		on = true
+		if hasVal {
+			on, _ = strconv.ParseBool(val)
+		}
+	}
+	return on
+}
+
+func main() {
+	exhaustiveAnalyzer := exhaustive.Analyzer
+	if useSSA(os.Args[1:]) {
+		exhaustiveAnalyzer = exhaustive.SSAAnalyzer
+	}
+	multichecker.Main(
+		closedconv.Analyzer,
+		zerovalue.Analyzer,
+		optional.Analyzer,
+		bitops.Analyzer,
+		enumarith.Analyzer,
+		impossible.Analyzer,
+		exhaustiveAnalyzer,
+	)
+}
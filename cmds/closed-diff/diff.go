@@ -0,0 +1,99 @@
+//Command closed-diff compares the closed types of two versions of a package
+//and reports the changes to them, marking those that may break users of the package.
+//
+//The versions are the packages in two directories, such as two git worktrees,
+//	closed-diff old/dir new/dir
+//or, with -rev, the package in a directory and the same package at a git revision,
+//	closed-diff -rev v1.2.0 dir
+//
+//It exits with status 1 if there are breaking changes,
+//so that it may be used in release checks.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jimmyfrasche/closed/apidiff"
+	"github.com/jimmyfrasche/closed/cmds/internal/tools"
+)
+
+func failOn(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	log.SetPrefix(fmt.Sprintf("%s: ", os.Args[0]))
+	log.SetFlags(0)
+
+	tools.AddTagsFlagDefault()
+	var (
+		rev      string
+		breaking bool
+	)
+	flag.StringVar(&rev, "rev", "", "compare the package in `dir` to its version at this git revision")
+	flag.BoolVar(&breaking, "breaking", false, "only print breaking changes")
+
+	flag.Usage = func() {
+		log.SetPrefix("")
+		log.Printf("usage: %s [flags] old new\n       %s [flags] -rev revision dir", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var (
+		broke bool
+		err   error
+	)
+	switch {
+	case rev == "" && flag.NArg() == 2:
+		broke, err = diff(flag.Arg(0), flag.Arg(1), breaking)
+	case rev != "" && flag.NArg() == 1:
+		broke, err = diffRev(rev, flag.Arg(0), breaking)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	failOn(err)
+	if broke {
+		os.Exit(1)
+	}
+}
+
+//diffRev compares dir to its version at rev.
+func diffRev(rev, dir string, breaking bool) (bool, error) {
+	old, cleanup, err := tools.Worktree(rev, dir)
+	if err != nil {
+		return false, err
+	}
+	defer cleanup()
+	return diff(old, dir, breaking)
+}
+
+//diff prints the changes from the package in old to the package in new
+//and reports whether any are breaking.
+func diff(old, new string, breaking bool) (bool, error) {
+	olds, err := tools.ClosedTypesInDir(old)
+	if err != nil {
+		return false, err
+	}
+	news, err := tools.ClosedTypesInDir(new)
+	if err != nil {
+		return false, err
+	}
+
+	cs := apidiff.Changes(olds, news)
+	for _, c := range cs {
+		switch {
+		case c.Breaking:
+			fmt.Println("breaking:", c)
+		case !breaking:
+			fmt.Println("compatible:", c)
+		}
+	}
+	return apidiff.Breaking(cs), nil
+}
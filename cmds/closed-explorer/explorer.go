@@ -1,21 +1,28 @@
-//Command closed-explorer analyzes a package and prints its closed types to stdout.
+//Command closed-explorer analyzes packages and prints their closed types to stdout.
+//
+//The packages, and their dependencies, are loaded and analyzed concurrently,
+//but printed in the order given.
+//Packages found in the cache are printed without being loaded.
+//
+//With -why T, instead explain why the type T in each package
+//was, or was not, recognized as closed.
 package main
 
 import (
 	"flag"
 	"fmt"
 	"go/build"
-	"go/importer"
 	"go/parser"
-	"go/token"
-	"go/types"
 	"log"
 	"os"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/jimmyfrasche/closed"
-	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+	"github.com/jimmyfrasche/closed/cmds/internal/cache"
 	"github.com/jimmyfrasche/closed/cmds/internal/tools"
+	"golang.org/x/tools/go/loader"
 )
 
 func failOn(err error) {
@@ -24,116 +31,277 @@ func failOn(err error) {
 	}
 }
 
+var why = flag.String("why", "", "explain why the type `T` is or is not closed instead of exploring")
+
 func main() {
 	log.SetFlags(0)
 
 	tools.AddTagsFlagDefault()
+	cache.AddFlagDefault()
 	flag.Parse()
 
 	tags := build.Default.BuildTags
 	imps, err := tools.GoList(tags, flag.Args())
 	failOn(err)
 
-	if len(imps) == 1 {
-		err := explore(tags, imps[0], skipImport)
+	if *why != "" {
+		prog, err := load(imps)
 		failOn(err)
+		if !explain(prog, imps, *why) {
+			os.Exit(1)
+		}
 		return
 	}
 
+	results, entries := lookup(imps)
+
+	//load and analyze the packages that are not cached all at once,
+	//printing the results in the order listed
+	var todo []int
+	for i, r := range results {
+		if r.types == nil {
+			todo = append(todo, i)
+		}
+	}
+	if len(todo) > 0 {
+		missing := make([]string, len(todo))
+		for j, i := range todo {
+			missing[j] = imps[i]
+		}
+		prog, err := load(missing)
+		failOn(err)
+		analyze(prog, todo, results, entries)
+	}
+
+	show := showImportsAndIndent
+	if len(imps) == 1 {
+		show = skipImport
+	}
+
 	failed := false
-	for _, imp := range imps {
-		err := explore(tags, imp, showImportsAndIndent)
-		if err != nil {
-			log.Print(err)
+	for _, r := range results {
+		if r.err != nil {
 			failed = true
 		}
+		if report(r.err) {
+			explore(r, show)
+		}
 	}
 	if failed {
 		os.Exit(1)
 	}
 }
 
-type showImport bool
+//A result is the closed types of a package, or why they could not be found.
+type result struct {
+	path  string
+	types []*closed.Portable
+	err   error
+}
 
-const (
-	skipImport           showImport = false
-	showImportsAndIndent showImport = true
-)
+//lookup imps in the cache, many at a time.
+//
+//The types of a result that is not found are nil.
+func lookup(imps []string) ([]result, []*cache.Entry) {
+	results := make([]result, len(imps))
+	entries := make([]*cache.Entry, len(imps))
 
-func explore(tags []string, imp string, showImport showImport) error {
-	ind := func() {
-		if showImport {
-			fmt.Print("\t")
-		}
-	}
+	//the packages share many imports, whose keys are only computed once
+	cs := cache.NewSession()
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for i, imp := range imps {
+		results[i].path = imp
+		wg.Add(1)
+		go func(i int, imp string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-	bi, err := build.Import(imp, "", 0)
-	if err != nil {
-		return err
+			entries[i] = cs.OpenImport(imp)
+			if ps, ok := entries[i].Portables(); ok {
+				if ps == nil {
+					ps = []*closed.Portable{}
+				}
+				results[i].types = ps
+			}
+		}(i, imp)
 	}
+	wg.Wait()
+	return results, entries
+}
 
-	fs := token.NewFileSet()
+//analyze the packages of results at todo, loaded in prog,
+//caching any found without error.
+func analyze(prog *loader.Program, todo []int, results []result, entries []*cache.Entry) {
+	var (
+		pkgs []*closed.Package
+		idx  []int
+	)
+	for _, i := range todo {
+		info := prog.Package(results[i].path)
+		switch {
+		case info == nil:
+			results[i].err = fmt.Errorf("could not load %q", results[i].path)
+			continue
+		case len(info.Errors) > 0:
+			results[i].err = info.Errors[0]
+			continue
+		}
+		pkgs = append(pkgs, &closed.Package{
+			Files: info.Files,
+			Types: info.Pkg,
+		})
+		idx = append(idx, i)
+	}
+	for j, r := range closed.NewSession(0).InPackages(prog.Fset, pkgs, 0) {
+		i := idx[j]
+		if r.Err == nil {
+			entries[i].Put(r.Types)
+		}
+		results[i].err = r.Err
+		results[i].types = []*closed.Portable{}
+		for _, t := range r.Types {
+			p, err := closed.ToPortable(t)
+			if err != nil {
+				results[i].err = err
+				break
+			}
+			results[i].types = append(results[i].types, p)
+		}
+	}
+}
 
-	pkgs, err := parser.ParseDir(fs, bi.Dir, tools.MakeFileCheck(bi.GoFiles), parser.ParseComments)
+//report err and whether there are results to explore regardless.
+func report(err error) bool {
+	if es, ok := err.(closed.Errors); ok {
+		for _, e := range es {
+			log.Print(e)
+		}
+		return true
+	}
 	if err != nil {
-		return err
+		log.Print(err)
+		return false
 	}
+	return true
+}
 
-	files := tools.FilesToSlice(pkgs[bi.Name])
-
-	cfg := types.Config{
-		Importer: importer.Default(),
+//explain why the type named T in each of imps is or is not closed
+//and report whether it was found in every package.
+func explain(prog *loader.Program, imps []string, T string) bool {
+	ok := true
+	for _, imp := range imps {
+		info := prog.Package(imp)
+		switch {
+		case info == nil:
+			log.Printf("could not load %q", imp)
+			ok = false
+			continue
+		case len(info.Errors) > 0:
+			log.Print(info.Errors[0])
+			ok = false
+			continue
+		}
+		_, es, err := closed.Explain(prog.Fset, info.Files, info.Pkg)
+		report(err)
+		found := false
+		for _, e := range es {
+			if e.Type.Name() != T {
+				continue
+			}
+			found = true
+			state := "not closed"
+			if e.Closed {
+				state = "closed"
+			}
+			fmt.Printf("%s.%s: %s: %s\n", imp, T, state, e.Reason)
+		}
+		if !found {
+			log.Printf("no defined type %s in %q", T, imp)
+			ok = false
+		}
 	}
+	return ok
+}
 
-	pkg, err := cfg.Check(bi.ImportPath, fs, files, nil)
-	if err != nil {
-		return err
+//load and type check imps and their dependencies,
+//each dependency only once and many at a time.
+//
+//Function bodies are not needed to extract closed types, so they are not checked.
+func load(imps []string) (*loader.Program, error) {
+	cfg := &loader.Config{
+		ParserMode:          parser.ParseComments,
+		TypeCheckFuncBodies: func(string) bool { return false },
+		Build:               &build.Default,
+		AllowErrors:         true,
+	}
+	//don't spam stderr with every error, the first of each package is reported
+	cfg.TypeChecker.Error = func(error) {}
+	for _, imp := range imps {
+		cfg.Import(imp)
 	}
+	return cfg.Load()
+}
 
-	vs, err := closed.InPackage(fs, files, pkg)
-	if err != nil {
-		return err
+type showImport bool
+
+const (
+	skipImport           showImport = false
+	showImportsAndIndent showImport = true
+)
+
+func explore(r result, showImport showImport) {
+	ind := func() {
+		if showImport {
+			fmt.Print("\t")
+		}
 	}
 
+	vs := r.types
 	if showImport {
-		fmt.Printf("%s (%d)\n", bi.ImportPath, len(vs))
+		fmt.Printf("%s (%d)\n", r.path, len(vs))
 	}
 
 	for _, v := range vs {
-		switch v := v.(type) {
-		case *closed.Enum:
+		switch v.Kind {
+		case "Enum":
 			ind()
-			fmt.Println("Enum:", name(v))
-			if !v.NonZero && !closedutil.ContainsLabeledZero(v) {
+			if v.Ordered {
+				fmt.Println("Ordered enum:", v.Types[0])
+			} else {
+				fmt.Println("Enum:", v.Types[0])
+			}
+			if !v.NonZero && !v.LabeledZero {
 				ind()
 				fmt.Println("\t0")
 			}
 			for _, lbl := range v.Labels {
 				ind()
-				fmt.Printf("\t%s\n", labels(lbl))
+				fmt.Printf("\t%s\n", strings.Join(lbl, " = "))
 			}
 			fmt.Println()
 
-		case *closed.Bitset:
+		case "Bitset":
 			ind()
-			fmt.Println("Bitset:", name(v))
-			for _, f := range v.Flags {
+			fmt.Println("Bitset:", v.Types[0])
+			for _, f := range v.Labels {
 				ind()
-				fmt.Printf("\t%s\n", labels(f))
+				fmt.Printf("\t%s\n", strings.Join(f, " = "))
 			}
 			if len(v.OrFlags) > 1 {
 				ind()
 				fmt.Println("\t| flags")
 				for _, f := range v.OrFlags {
 					ind()
-					fmt.Printf("\t\t%s\n", labels(f))
+					fmt.Printf("\t\t%s\n", strings.Join(f, " = "))
 				}
 			}
 			fmt.Println()
 
-		case *closed.Interface:
+		case "Interface":
 			ind()
-			fmt.Println("Sum iface:", name(v))
+			fmt.Println("Sum iface:", v.Types[0])
 			ind()
 			fmt.Println("\ttags methods:")
 			for _, t := range v.TagMethods {
@@ -160,56 +328,54 @@ func explore(tags []string, imp string, showImport showImport) error {
 			}
 			fmt.Println()
 
-		case *closed.EmptySum:
+		case "EmptySum":
 			ind()
-			fmt.Println("Empty sum:", name(v))
+			fmt.Println("Empty sum:", v.Types[0])
 			if v.Nil {
 				ind()
 				fmt.Println("\t<nil>")
 			}
 			for _, m := range v.Members {
 				ind()
-				fmt.Printf("\t%s\n", types.TypeString(m, nil))
+				fmt.Printf("\t%s\n", typeString(m))
 			}
 
-		case *closed.OptionalStruct:
+		case "OptionalStruct":
 			ind()
-			fmt.Println("Optional struct:", name(v))
+			fmt.Println("Optional struct:", v.Types[0])
 			ind()
-			fmt.Printf("\tDiscriminant: %s\n", v.Discriminant.Name())
+			fmt.Printf("\tDiscriminant: %s\n", v.Discriminant)
 			ind()
-			fmt.Printf("\tOptional: %s\n", v.Field.Name())
+			fmt.Printf("\tOptional: %s\n", v.Field)
 			fmt.Println()
 
 		default:
 			//this is serious so we just explode rather than spam stderr
-			log.Fatalf("need to update explorer, new type %T added", v)
+			log.Fatalf("need to update explorer, new type %s added", v.Kind)
 		}
 	}
-
-	return err
-}
-
-func name(t closed.Type) string {
-	return t.Types()[0].Name()
-}
-
-func labels(lbl []*types.Const) string {
-	var nms []string
-	for _, c := range lbl {
-		nms = append(nms, c.Name())
-	}
-	return strings.Join(nms, " = ")
 }
 
-func typeNames(t *closed.TypeNamesAndType) string {
+func typeNames(t closed.PortableType) string {
 	prefix := ""
-	if _, ptr := t.Type.Underlying().(*types.Pointer); ptr {
+	if t.Ptr {
 		prefix = "*"
 	}
 	var acc []string
-	for _, n := range t.TypeName {
-		acc = append(acc, prefix+n.Name())
+	for _, n := range t.Names {
+		acc = append(acc, prefix+n)
 	}
 	return strings.Join(acc, " = ")
 }
+
+//typeString is types.TypeString of the member t, qualified by its import path.
+func typeString(t closed.PortableType) string {
+	s := t.Names[0]
+	if t.Path != "" {
+		s = t.Path + "." + s
+	}
+	if t.Ptr {
+		s = "*" + s
+	}
+	return s
+}
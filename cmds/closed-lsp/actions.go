@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"io"
+	"strings"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+	"github.com/jimmyfrasche/closed/cmds/internal/fill"
+	"github.com/jimmyfrasche/closed/cmds/internal/gen"
+	"github.com/jimmyfrasche/closed/cmds/internal/tools"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+//The commands behind each code action.
+const (
+	cmdFillSwitch        = "closed.fillSwitch"
+	cmdGenerateValidator = "closed.generateValidator"
+	cmdGenerateString    = "closed.generateString"
+)
+
+//fillArgs are the arguments of cmdFillSwitch.
+type fillArgs struct {
+	URI    string `json:"uri"`
+	Offset int    `json:"offset"`
+	//New is true if there is no switch and one must be created.
+	New bool `json:"new"`
+}
+
+//typeArgs are the arguments of cmdGenerateValidator and cmdGenerateString.
+type typeArgs struct {
+	URI  string `json:"uri"`
+	Type string `json:"type"`
+}
+
+func mkCommand(title, cmd string, args interface{}) (*CodeAction, error) {
+	p, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	return &CodeAction{
+		Title: title,
+		Kind:  "refactor.rewrite",
+		Command: &Command{
+			Title:     title,
+			Command:   cmd,
+			Arguments: []json.RawMessage{p},
+		},
+	}, nil
+}
+
+//codeActions available at the start of the requested range.
+func (sv *server) codeActions(p CodeActionParams) ([]*CodeAction, error) {
+	acts := []*CodeAction{}
+
+	fn, err := uriToFilename(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := sv.s.docs[fn]
+	if !ok {
+		return acts, nil
+	}
+	v, err := sv.s.viewOf(fn)
+	if err != nil {
+		return nil, err
+	}
+	f, err := v.file(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := offsetOf(d.text, p.Range.Start)
+
+	if _, err := fill.At(v.prog, v.pkg, f, 0, offset, v.closedTypesIn); err == nil {
+		a, err := mkCommand("Fill switch", cmdFillSwitch, &fillArgs{URI: d.uri, Offset: offset})
+		if err != nil {
+			return nil, err
+		}
+		acts = append(acts, a)
+	} else if _, err := fill.New(v.prog, v.pkg, f, offset, v.closedTypesIn); err == nil {
+		a, err := mkCommand("Generate switch", cmdFillSwitch, &fillArgs{URI: d.uri, Offset: offset, New: true})
+		if err != nil {
+			return nil, err
+		}
+		acts = append(acts, a)
+	}
+
+	ct, err := sv.closedTypeAt(v, f, offset)
+	if err != nil || ct == nil {
+		return acts, nil
+	}
+	name := ct.Types()[0].Name()
+	args := &typeArgs{URI: d.uri, Type: name}
+
+	a, err := mkCommand(fmt.Sprintf("Generate validator for %s", name), cmdGenerateValidator, args)
+	if err != nil {
+		return nil, err
+	}
+	acts = append(acts, a)
+
+	switch ct.(type) {
+	case *closed.Enum, *closed.Bitset:
+		if !hasString(ct.Types()[0]) {
+			a, err := mkCommand(fmt.Sprintf("Generate String method for %s", name), cmdGenerateString, args)
+			if err != nil {
+				return nil, err
+			}
+			acts = append(acts, a)
+		}
+	}
+
+	return acts, nil
+}
+
+//closedTypeAt returns the closed type declared by the type spec at offset, if any.
+func (sv *server) closedTypeAt(v *view, f *ast.File, offset int) (closed.Type, error) {
+	pos := v.prog.Fset.File(f.Pos()).Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	for _, n := range path {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+		t, ok := v.pkg.Info.Defs[ts.Name].(*types.TypeName)
+		if !ok {
+			return nil, nil
+		}
+		cts, err := v.closedTypes()
+		if err != nil {
+			return nil, err
+		}
+		return closedutil.Find(t, cts), nil
+	}
+	return nil, nil
+}
+
+func hasString(t *types.TypeName) bool {
+	o, _, _ := types.LookupFieldOrMethod(t.Type(), false, t.Pkg(), "String")
+	return o != nil
+}
+
+func (sv *server) executeCommand(p ExecuteCommandParams) error {
+	if len(p.Arguments) != 1 {
+		return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("%s takes one argument", p.Command)}
+	}
+	arg := p.Arguments[0]
+
+	var edit *ApplyWorkspaceEditParams
+	var err error
+	switch p.Command {
+	case cmdFillSwitch:
+		var a fillArgs
+		if err := json.Unmarshal(arg, &a); err != nil {
+			return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		edit, err = sv.fillSwitch(a)
+
+	case cmdGenerateValidator, cmdGenerateString:
+		var a typeArgs
+		if err := json.Unmarshal(arg, &a); err != nil {
+			return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		edit, err = sv.generate(p.Command, a)
+
+	default:
+		return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown command %s", p.Command)}
+	}
+	if err != nil {
+		return err
+	}
+
+	return sv.conn.call("workspace/applyEdit", edit)
+}
+
+//fillSwitch returns an edit replacing the document with one
+//where the requested switch is filled.
+func (sv *server) fillSwitch(a fillArgs) (*ApplyWorkspaceEditParams, error) {
+	fn, err := uriToFilename(a.URI)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := sv.s.docs[fn]
+	if !ok {
+		return nil, fmt.Errorf("%s is not open", a.URI)
+	}
+	v, err := sv.s.viewOf(fn)
+	if err != nil {
+		return nil, err
+	}
+	f, err := v.file(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var sw *fill.Switch
+	if a.New {
+		sw, err = fill.New(v.prog, v.pkg, f, a.Offset, v.closedTypesIn)
+	} else {
+		sw, err = fill.At(v.prog, v.pkg, f, 0, a.Offset, v.closedTypesIn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//Fill modifies the syntax of the view so it cannot be used again.
+	sv.s.invalidate(fn)
+
+	if err := sw.Fill(fill.Options{}); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, v.prog.Fset, f); err != nil {
+		return nil, err
+	}
+
+	version := d.version
+	return &ApplyWorkspaceEditParams{
+		Label: "Fill switch",
+		Edit: WorkspaceEdit{
+			DocumentChanges: []interface{}{
+				&TextDocumentEdit{
+					TextDocument: VersionedTextDocumentIdentifier{
+						URI:     d.uri,
+						Version: &version,
+					},
+					Edits: []TextEdit{{
+						Range: Range{
+							End: positionOf(d.text, len(d.text)),
+						},
+						NewText: buf.String(),
+					}},
+				},
+			},
+		},
+	}, nil
+}
+
+//generate returns an edit creating a new file containing
+//a validator or String method for the requested type.
+func (sv *server) generate(cmd string, a typeArgs) (*ApplyWorkspaceEditParams, error) {
+	fn, err := uriToFilename(a.URI)
+	if err != nil {
+		return nil, err
+	}
+	v, err := sv.s.viewOf(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := v.pkg.Pkg.Scope().Lookup(a.Type).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("no type %s in %q", a.Type, v.imp)
+	}
+	cts, err := v.closedTypes()
+	if err != nil {
+		return nil, err
+	}
+	ct := closedutil.Find(t, cts)
+	if ct == nil {
+		return nil, fmt.Errorf("%s is not recognized as a closed type: %s", a.Type, closedutil.WhyNot(v.prog.Fset, v.pkg.Files, t))
+	}
+
+	definedIn := v.prog.Fset.Position(t.Pos()).Filename
+	buildTags, err := tools.BuildTagsFrom(definedIn)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(definedIn, ".go")
+
+	var (
+		toolName, label, out string
+		g                    interface{ Generate(io.Writer) error }
+	)
+	switch cmd {
+	case cmdGenerateValidator:
+		//use clvalid's name and header so either tool may regenerate the file
+		toolName = "clvalid"
+		label = "Generate validator"
+		out = prefix + "_clvalid.go"
+
+		imports, names, err := gen.Imports(v.imp, ct)
+		if err != nil {
+			return nil, err
+		}
+		mkfunc := gen.MustFunc(ct)
+		g = &gen.Validator{
+			ToolName:       toolName,
+			Name:           t.Name(),
+			ImportPath:     v.imp,
+			T:              ct,
+			FName:          gen.ValidatorName(t.Name(), mkfunc),
+			Func:           mkfunc,
+			BuildTags:      buildTags,
+			PackageName:    v.pkg.Pkg.Name(),
+			ThisPackageImp: v.imp,
+			ImportNames:    names,
+			Imports:        imports,
+		}
+
+	case cmdGenerateString:
+		toolName = "closed-lsp"
+		label = "Generate String method"
+		out = prefix + "_string.go"
+		g = &gen.Stringer{
+			ToolName:    toolName,
+			Name:        t.Name(),
+			T:           ct,
+			BuildTags:   buildTags,
+			PackageName: v.pkg.Pkg.Name(),
+		}
+	}
+
+	if err := tools.OverwriteCheck(out, toolName); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := g.Generate(&buf); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	uri := filenameToURI(out)
+	return &ApplyWorkspaceEditParams{
+		Label: label,
+		Edit: WorkspaceEdit{
+			DocumentChanges: []interface{}{
+				&CreateFile{
+					Kind:    "create",
+					URI:     uri,
+					Options: CreateFileOptions{Overwrite: true},
+				},
+				&TextDocumentEdit{
+					TextDocument: VersionedTextDocumentIdentifier{URI: uri},
+					Edits: []TextEdit{{
+						NewText: string(src),
+					}},
+				},
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+//A message is a JSON-RPC 2.0 request, notification, or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+//isResponse reports whether m is a response to a request we sent.
+func (m *message) isResponse() bool {
+	return m.Method == ""
+}
+
+//response is a message that always includes its result, even when null.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+//JSON-RPC error codes used by this server.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+//A conn reads and writes messages framed by a Content-Length header.
+type conn struct {
+	r *bufio.Reader
+
+	mu     sync.Mutex
+	w      io.Writer
+	nextID int
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{
+		r: bufio.NewReader(r),
+		w: w,
+	}
+}
+
+func (c *conn) read() (*message, error) {
+	hdr, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(hdr.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %s", err)
+	}
+
+	p := make([]byte, n)
+	if _, err := io.ReadFull(c.r, p); err != nil {
+		return nil, err
+	}
+
+	m := &message{}
+	if err := json.Unmarshal(p, m); err != nil {
+		return nil, &rpcError{Code: codeParseError, Message: err.Error()}
+	}
+	return m, nil
+}
+
+func (c *conn) write(v interface{}) error {
+	p, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(p)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(p)
+	return err
+}
+
+//reply to the request with id.
+//If err is not nil, result is ignored.
+func (c *conn) reply(id json.RawMessage, result interface{}, err error) error {
+	r := &response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  json.RawMessage("null"),
+	}
+	if err != nil {
+		e, ok := err.(*rpcError)
+		if !ok {
+			e = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		r.Error = e
+	} else if result != nil {
+		p, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		r.Result = p
+	}
+	return c.write(r)
+}
+
+//notify the client.
+func (c *conn) notify(method string, params interface{}) error {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  p,
+	})
+}
+
+//call sends a request to the client.
+//The response is read, and discarded, by the server loop.
+func (c *conn) call(method string, params interface{}) error {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+
+	return c.write(&message{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(strconv.Itoa(id)),
+		Method:  method,
+		Params:  p,
+	})
+}
@@ -0,0 +1,48 @@
+//Command closed-lsp is a language server providing editor support for closed types.
+//
+//It speaks the Language Server Protocol over stdin and stdout and offers
+//	* a warning on each switch over a closed type that is missing cases
+//	* a code action to fill in the missing cases of a switch, as fillswitch(1) does,
+//	  or to create a complete switch over the expression at the cursor
+//	* a code action to generate a validator for a closed type, as clvalid(1) does
+//	* a code action to generate a String method for an enum or bitset
+//
+//Packages are found as the go tool would, with respect to -tags.
+//The open documents are used in place of the files on disk.
+//When a document is edited only its package is checked again,
+//unless its imports change, as its dependencies are kept loaded.
+package main
+
+import (
+	"flag"
+	"go/build"
+	"log"
+	"os"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/tools"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("closed-lsp: ")
+
+	tools.AddTagsFlagDefault()
+	flag.Usage = func() {
+		log.Printf("usage: %s [flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sv := newServer(os.Stdin, os.Stdout, &build.Default)
+	if err := sv.serve(); err != nil {
+		log.Fatal(err)
+	}
+	//the protocol requires exiting with 1 if the client did not shutdown first
+	if !sv.shutdown {
+		os.Exit(1)
+	}
+}
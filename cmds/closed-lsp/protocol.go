@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+//The subset of the Language Server Protocol used by this server.
+
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version *int   `json:"version"`
+}
+
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   ServerInfo         `json:"serverInfo"`
+}
+
+type ServerInfo struct {
+	Name string `json:"name"`
+}
+
+type ServerCapabilities struct {
+	//TextDocumentSync is always full.
+	TextDocumentSync       int                   `json:"textDocumentSync"`
+	CodeActionProvider     bool                  `json:"codeActionProvider"`
+	ExecuteCommandProvider ExecuteCommandOptions `json:"executeCommandProvider"`
+}
+
+const textDocumentSyncFull = 1
+
+type ExecuteCommandOptions struct {
+	Commands []string `json:"commands"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []TextDocumentContentChange     `json:"contentChanges"`
+}
+
+//TextDocumentContentChange is always the full text of the document.
+type TextDocumentContentChange struct {
+	Text string `json:"text"`
+}
+
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+type CodeAction struct {
+	Title   string   `json:"title"`
+	Kind    string   `json:"kind"`
+	Command *Command `json:"command"`
+}
+
+type Command struct {
+	Title     string            `json:"title"`
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments"`
+}
+
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments"`
+}
+
+type ApplyWorkspaceEditParams struct {
+	Label string        `json:"label"`
+	Edit  WorkspaceEdit `json:"edit"`
+}
+
+//WorkspaceEdit.DocumentChanges are *CreateFile or *TextDocumentEdit.
+type WorkspaceEdit struct {
+	DocumentChanges []interface{} `json:"documentChanges"`
+}
+
+type TextDocumentEdit struct {
+	TextDocument VersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                      `json:"edits"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type CreateFile struct {
+	Kind    string            `json:"kind"`
+	URI     string            `json:"uri"`
+	Options CreateFileOptions `json:"options"`
+}
+
+type CreateFileOptions struct {
+	Overwrite bool `json:"overwrite"`
+}
+
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+const severityWarning = 2
+
+func uriToFilename(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+func filenameToURI(filename string) string {
+	u := &url.URL{
+		Scheme: "file",
+		Path:   filepath.ToSlash(filename),
+	}
+	return u.String()
+}
+
+//offsetOf converts p, whose Character is in UTF-16 code units,
+//to a byte offset in text.
+func offsetOf(text []byte, p Position) int {
+	off, line := 0, 0
+	for line < p.Line && off < len(text) {
+		if text[off] == '\n' {
+			line++
+		}
+		off++
+	}
+
+	for units := 0; units < p.Character && off < len(text) && text[off] != '\n'; {
+		r, sz := utf8.DecodeRune(text[off:])
+		units += utf16.RuneLen(r)
+		off += sz
+	}
+	return off
+}
+
+//positionOf converts the byte offset off in text to a Position.
+func positionOf(text []byte, off int) Position {
+	var p Position
+	for i := 0; i < off && i < len(text); {
+		r, sz := utf8.DecodeRune(text[i:])
+		if r == '\n' {
+			p.Line++
+			p.Character = 0
+		} else {
+			p.Character += utf16.RuneLen(r)
+		}
+		i += sz
+	}
+	return p
+}
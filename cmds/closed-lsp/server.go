@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/fill"
+)
+
+//A server handles the requests of a single client.
+type server struct {
+	conn *conn
+	s    *session
+
+	shutdown bool
+}
+
+func newServer(r io.Reader, w io.Writer, bc *build.Context) *server {
+	return &server{
+		conn: newConn(r, w),
+		s:    newSession(bc),
+	}
+}
+
+//serve until the client exits or the connection is closed.
+func (sv *server) serve() error {
+	for {
+		m, err := sv.conn.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if _, ok := err.(*rpcError); ok {
+				//the id of a message that cannot be parsed is unknown
+				if err := sv.conn.reply(json.RawMessage("null"), nil, err); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		//responses to our workspace/applyEdit requests
+		if m.isResponse() {
+			if m.Error != nil {
+				log.Print(m.Error)
+			}
+			continue
+		}
+
+		if m.Method == "exit" {
+			return nil
+		}
+
+		result, err := sv.handle(m)
+		if m.ID == nil {
+			//notification
+			if err != nil {
+				log.Printf("%s: %s", m.Method, err)
+			}
+			continue
+		}
+		if err := sv.conn.reply(m.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (sv *server) handle(m *message) (interface{}, error) {
+	decode := func(v interface{}) error {
+		if err := json.Unmarshal(m.Params, v); err != nil {
+			return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch m.Method {
+	case "initialize":
+		return &InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   textDocumentSyncFull,
+				CodeActionProvider: true,
+				ExecuteCommandProvider: ExecuteCommandOptions{
+					Commands: []string{cmdFillSwitch, cmdGenerateValidator, cmdGenerateString},
+				},
+			},
+			ServerInfo: ServerInfo{Name: "closed-lsp"},
+		}, nil
+
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		sv.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return nil, sv.didChange(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		version := 0
+		if p.TextDocument.Version != nil {
+			version = *p.TextDocument.Version
+		}
+		//the last change is the full text as we only support full sync
+		text := p.ContentChanges[len(p.ContentChanges)-1].Text
+		return nil, sv.didChange(p.TextDocument.URI, version, text)
+
+	case "textDocument/didSave":
+		var p DidSaveTextDocumentParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		fn, err := uriToFilename(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return nil, sv.publishDiagnostics(fn)
+
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		fn, err := uriToFilename(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		sv.s.close(fn)
+		return nil, sv.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+
+	case "textDocument/codeAction":
+		var p CodeActionParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return sv.codeActions(p)
+
+	case "workspace/executeCommand":
+		var p ExecuteCommandParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return nil, sv.executeCommand(p)
+	}
+
+	if strings.HasPrefix(m.Method, "$/") {
+		//optional notifications may be ignored
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not supported: %s", m.Method)}
+}
+
+func (sv *server) didChange(uri string, version int, text string) error {
+	fn, err := uriToFilename(uri)
+	if err != nil {
+		return err
+	}
+	sv.s.update(&document{
+		uri:      uri,
+		filename: fn,
+		version:  version,
+		text:     []byte(text),
+	})
+	return sv.publishDiagnostics(fn)
+}
+
+//publishDiagnostics for every switch over a closed type in filename
+//that is missing cases.
+func (sv *server) publishDiagnostics(filename string) error {
+	d, ok := sv.s.docs[filename]
+	if !ok {
+		return nil
+	}
+
+	v, err := sv.s.viewOf(filename)
+	if err != nil {
+		return err
+	}
+	f, err := v.file(filename)
+	if err != nil {
+		return err
+	}
+
+	diags := []Diagnostic{}
+	for _, sw := range fill.All(v.prog, v.pkg, f, v.closedTypesIn) {
+		missing, err := sw.Missing()
+		if err != nil || len(missing) == 0 {
+			continue
+		}
+
+		start := v.prog.Fset.Position(sw.Stmt.Pos()).Offset
+		end := start + len("switch")
+		diags = append(diags, Diagnostic{
+			Range: Range{
+				Start: positionOf(d.text, start),
+				End:   positionOf(d.text, end),
+			},
+			Severity: severityWarning,
+			Source:   "closed",
+			Message:  fmt.Sprintf("switch over %s is missing cases: %s", sw.Closed.Types()[0].Name(), strings.Join(missing, ", ")),
+		})
+	}
+
+	return sv.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         d.uri,
+		Diagnostics: diags,
+	})
+}
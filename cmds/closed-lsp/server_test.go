@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/fill"
+)
+
+const testSrc = `package ex
+
+type Enum int
+
+const (
+	A Enum = iota + 1
+	B
+	C
+)
+
+func f(x Enum) {
+	switch x {
+	case A:
+	}
+}
+`
+
+//client is the editor side of a connection to a server.
+type client struct {
+	t    *testing.T
+	conn *conn
+	id   int
+}
+
+func (c *client) send(method string, params interface{}, notification bool) {
+	c.t.Helper()
+	p, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	m := &message{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  p,
+	}
+	if !notification {
+		c.id++
+		m.ID = json.RawMessage(strconv.Itoa(c.id))
+	}
+	if err := c.conn.write(m); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+//expect reads messages until one with method, or a response if method is "".
+func (c *client) expect(method string) *message {
+	c.t.Helper()
+	for {
+		m, err := c.conn.read()
+		if err != nil {
+			c.t.Fatalf("waiting for %q: %s", method, err)
+		}
+		if m.Error != nil {
+			c.t.Fatalf("waiting for %q: %s", method, m.Error)
+		}
+		if m.Method == method {
+			return m
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	gopath := t.TempDir()
+	dir := filepath.Join(gopath, "src", "ex")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "ex.go")
+	//the file on disk is stale: only the open document should be used
+	if err := os.WriteFile(filename, []byte("package ex\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GO111MODULE", "off")
+
+	bc := build.Default
+	bc.GOPATH = gopath
+
+	sr, cw := io.Pipe()
+	cr, sw := io.Pipe()
+	sv := newServer(sr, sw, &bc)
+	done := make(chan error, 1)
+	go func() {
+		done <- sv.serve()
+		sw.Close()
+	}()
+	c := &client{t: t, conn: newConn(cr, cw)}
+
+	c.send("initialize", struct{}{}, false)
+	c.expect("")
+	c.send("initialized", struct{}{}, true)
+
+	uri := filenameToURI(filename)
+	c.send("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:        uri,
+			LanguageID: "go",
+			Version:    1,
+			Text:       testSrc,
+		},
+	}, true)
+
+	var diags PublishDiagnosticsParams
+	if err := json.Unmarshal(c.expect("textDocument/publishDiagnostics").Params, &diags); err != nil {
+		t.Fatal(err)
+	}
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic got %#v", diags.Diagnostics)
+	}
+	if msg := diags.Diagnostics[0].Message; !strings.Contains(msg, "missing cases: B, C") {
+		t.Fatalf("unexpected diagnostic %q", msg)
+	}
+
+	at := positionOf([]byte(testSrc), strings.Index(testSrc, "switch"))
+	c.send("textDocument/codeAction", &CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Range:        Range{Start: at, End: at},
+	}, false)
+	var acts []*CodeAction
+	if err := json.Unmarshal(c.expect("").Result, &acts); err != nil {
+		t.Fatal(err)
+	}
+	var fillSwitch *Command
+	for _, a := range acts {
+		if a.Command.Command == cmdFillSwitch {
+			fillSwitch = a.Command
+		}
+	}
+	if fillSwitch == nil {
+		t.Fatalf("no fill switch action in %#v", acts)
+	}
+
+	c.send("workspace/executeCommand", &ExecuteCommandParams{
+		Command:   fillSwitch.Command,
+		Arguments: fillSwitch.Arguments,
+	}, false)
+
+	var edit struct {
+		Edit struct {
+			DocumentChanges []TextDocumentEdit `json:"documentChanges"`
+		} `json:"edit"`
+	}
+	if err := json.Unmarshal(c.expect("workspace/applyEdit").Params, &edit); err != nil {
+		t.Fatal(err)
+	}
+	changes := edit.Edit.DocumentChanges
+	if len(changes) != 1 || len(changes[0].Edits) != 1 {
+		t.Fatalf("unexpected edit %#v", changes)
+	}
+	if text := changes[0].Edits[0].NewText; !strings.Contains(text, "case B:") || !strings.Contains(text, "case C:") {
+		t.Fatalf("switch not filled:\n%s", text)
+	}
+	c.expect("")
+
+	//type spec of Enum
+	at = positionOf([]byte(testSrc), strings.Index(testSrc, "Enum int"))
+	c.send("textDocument/codeAction", &CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Range:        Range{Start: at, End: at},
+	}, false)
+	acts = nil
+	if err := json.Unmarshal(c.expect("").Result, &acts); err != nil {
+		t.Fatal(err)
+	}
+	have := map[string]bool{}
+	for _, a := range acts {
+		have[a.Command.Command] = true
+	}
+	if !have[cmdGenerateValidator] || !have[cmdGenerateString] {
+		t.Fatalf("expected generate actions, got %#v", acts)
+	}
+
+	c.send("shutdown", nil, false)
+	c.expect("")
+	c.send("exit", nil, true)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !sv.shutdown {
+		t.Fatal("shutdown not recorded")
+	}
+}
+
+func TestParseError(t *testing.T) {
+	sr, cw := io.Pipe()
+	cr, sw := io.Pipe()
+	sv := newServer(sr, sw, &build.Default)
+	done := make(chan error, 1)
+	go func() {
+		done <- sv.serve()
+		sw.Close()
+	}()
+	c := newConn(cr, cw)
+
+	const bad = "{not json"
+	if _, err := io.WriteString(cw, "Content-Length: "+strconv.Itoa(len(bad))+"\r\n\r\n"+bad); err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Error == nil || m.Error.Code != codeParseError || string(m.ID) != "null" {
+		t.Fatalf("got %#v, want a parse error with a null id", m)
+	}
+
+	//the server is still usable
+	cl := &client{t: t, conn: c}
+	cl.send("shutdown", nil, false)
+	cl.expect("")
+	cl.send("exit", nil, true)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecheck(t *testing.T) {
+	gopath := t.TempDir()
+	write := func(path, src string) string {
+		t.Helper()
+		fn := filepath.Join(gopath, "src", path)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fn
+	}
+	depFn := write("dep/dep.go", "package dep\n\ntype Enum int\n\nconst (\n\tA Enum = iota + 1\n\tB\n\tC\n)\n")
+	fn := write("ex/ex.go", "package ex\n")
+	t.Setenv("GO111MODULE", "off")
+
+	bc := build.Default
+	bc.GOPATH = gopath
+	s := newSession(&bc)
+
+	edit := func(fn, src string) *view {
+		t.Helper()
+		s.update(&document{filename: fn, text: []byte(src)})
+		v, err := s.viewOf(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	missing := func(v *view) string {
+		t.Helper()
+		f, err := v.file(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sws := fill.All(v.prog, v.pkg, f, v.closedTypesIn)
+		if len(sws) != 1 {
+			t.Fatalf("got %d switches, want 1", len(sws))
+		}
+		m, err := sws[0].Missing()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(m, ", ")
+	}
+
+	const src = "package ex\n\nimport \"dep\"\n\nfunc f(x dep.Enum) {\n\tswitch x {\n\tcase dep.A:%s\n\t}\n}\n"
+	v1 := edit(fn, fmt.Sprintf(src, ""))
+	if got := missing(v1); got != "dep.B, dep.C" {
+		t.Fatalf("missing %s, want dep.B, dep.C", got)
+	}
+
+	//only ex is checked again
+	v2 := edit(fn, fmt.Sprintf(src, "\n\tcase dep.B:"))
+	if v2 == v1 {
+		t.Fatal("edited view not checked again")
+	}
+	if v2.prog.Package("dep") != v1.prog.Package("dep") {
+		t.Error("dependency loaded again")
+	}
+	if _, ok := v2.closed[v1.prog.Package("dep").Pkg]; !ok {
+		t.Error("closed types of dependency not kept")
+	}
+	if v2.prog.Package("ex") != v2.pkg {
+		t.Error("program does not have the checked package")
+	}
+	if got := missing(v2); got != "dep.C" {
+		t.Errorf("missing %s, want dep.C", got)
+	}
+
+	//a new import is not loaded, so everything is
+	v3 := edit(fn, strings.Replace(fmt.Sprintf(src, ""), `import "dep"`, "import (\n\t\"dep\"\n\t\"strings\"\n)\n\nvar _ = strings.ToUpper", 1))
+	if v3.prog.Package("strings") == nil {
+		t.Fatal("new import not loaded")
+	}
+	if got := missing(v3); got != "dep.B, dep.C" {
+		t.Errorf("missing %s, want dep.B, dep.C", got)
+	}
+
+	//editing a dependency loads everything again
+	s.update(&document{filename: depFn, text: []byte("package dep\n\ntype Enum int\n\nconst (\n\tA Enum = iota + 1\n\tB\n)\n")})
+	v4, err := s.viewOf(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v4.prog.Package("dep") == v3.prog.Package("dep") {
+		t.Error("edited dependency not loaded again")
+	}
+	if got := missing(v4); got != "dep.B" {
+		t.Errorf("missing %s, want dep.B", got)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+	"github.com/jimmyfrasche/closed/cmds/internal/guess"
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/loader"
+)
+
+//A document is a file open in the editor.
+type document struct {
+	uri, filename string
+	version       int
+	text          []byte
+}
+
+//A view is a loaded package and its dependencies.
+type view struct {
+	imp  string
+	prog *loader.Program
+	pkg  *loader.PackageInfo
+
+	//stale is set when a file of pkg has been edited,
+	//so that pkg must be checked again before the view is used.
+	stale bool
+
+	//closed caches the closed types of the packages in prog.
+	closed map[*types.Package][]closed.Type
+}
+
+//A session keeps the open documents and the packages they belong to loaded
+//so that requests do not need to reload the program each time.
+type session struct {
+	bc    *build.Context
+	docs  map[string]*document //by filename
+	views map[string]*view     //by import path
+}
+
+func newSession(bc *build.Context) *session {
+	return &session{
+		bc:    bc,
+		docs:  map[string]*document{},
+		views: map[string]*view{},
+	}
+}
+
+//context returns a build context that sees the open documents.
+func (s *session) context() *build.Context {
+	if len(s.docs) == 0 {
+		return s.bc
+	}
+	overlay := make(map[string][]byte, len(s.docs))
+	for fn, d := range s.docs {
+		overlay[fn] = d.text
+	}
+	return buildutil.OverlayContext(s.bc, overlay)
+}
+
+//update the text of the document, invalidating any views that depend on it.
+func (s *session) update(d *document) {
+	s.docs[d.filename] = d
+	s.invalidate(d.filename)
+}
+
+func (s *session) close(filename string) {
+	delete(s.docs, filename)
+	s.invalidate(filename)
+}
+
+//invalidate every view that includes the package containing filename.
+//
+//The view of that package only needs its package checked again,
+//as its dependencies are unchanged,
+//but views of the packages that import it must be loaded again.
+func (s *session) invalidate(filename string) {
+	_, imp, err := guess.ImportPath(filename, s.bc)
+	if err != nil {
+		return
+	}
+	for k, v := range s.views {
+		switch {
+		case k == imp && v.has(filename):
+			v.stale = true
+		case v.prog.Package(imp) != nil:
+			delete(s.views, k)
+		}
+	}
+}
+
+//viewOf loads, or returns the already loaded, package containing filename.
+func (s *session) viewOf(filename string) (*view, error) {
+	_, imp, err := guess.ImportPath(filename, s.bc)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := s.views[imp]; ok {
+		if !v.stale {
+			return v, nil
+		}
+		//if the package cannot be checked against the loaded dependencies,
+		//as when an import is added, load it all again
+		if v, err := s.recheck(v); err == nil {
+			s.views[imp] = v
+			return v, nil
+		}
+		delete(s.views, imp)
+	}
+
+	cfg := &loader.Config{
+		ParserMode: parser.ParseComments,
+		TypeCheckFuncBodies: func(p string) bool {
+			return p == imp
+		},
+		Build:       s.context(),
+		AllowErrors: true,
+	}
+	//the code being edited often does not type check,
+	//but the errors are not ours to report
+	cfg.TypeChecker.Error = func(error) {}
+	cfg.Import(imp)
+
+	prog, err := cfg.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &view{
+		imp:    imp,
+		prog:   prog,
+		pkg:    prog.Package(imp),
+		closed: map[*types.Package][]closed.Type{},
+	}
+	if v.pkg == nil {
+		return nil, fmt.Errorf("could not load %q", imp)
+	}
+	s.views[imp] = v
+	return v, nil
+}
+
+//recheck the package of v, which has been edited,
+//against the dependencies already loaded in v.
+func (s *session) recheck(v *view) (*view, error) {
+	//the packages imported by each import path in the files of v
+	imported := map[string]*types.Package{}
+	for _, f := range v.pkg.Files {
+		for _, is := range f.Imports {
+			path, err := strconv.Unquote(is.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+			var o types.Object
+			if is.Name != nil {
+				o = v.pkg.Defs[is.Name]
+			} else {
+				o = v.pkg.Implicits[is]
+			}
+			if pn, ok := o.(*types.PkgName); ok {
+				imported[path] = pn.Imported()
+			}
+		}
+	}
+
+	fs := v.prog.Fset
+	ctxt := s.context()
+	files := make([]*ast.File, len(v.pkg.Files))
+	for i, f := range v.pkg.Files {
+		name := fs.File(f.Pos()).Name()
+		rd, err := buildutil.OpenFile(ctxt, name)
+		if err != nil {
+			return nil, err
+		}
+		src, err := ioutil.ReadAll(rd)
+		rd.Close()
+		if err != nil {
+			return nil, err
+		}
+		//a file that cannot be parsed at all is still reported by the parser,
+		//and type checked as much as possible, as the loader would
+		files[i], _ = parser.ParseFile(fs, name, src, parser.ParseComments)
+		if files[i] == nil {
+			return nil, fmt.Errorf("could not parse %s", name)
+		}
+	}
+
+	info := &loader.PackageInfo{
+		Importable: true,
+		Files:      files,
+		Info: types.Info{
+			Types:      map[ast.Expr]types.TypeAndValue{},
+			Defs:       map[*ast.Ident]types.Object{},
+			Uses:       map[*ast.Ident]types.Object{},
+			Implicits:  map[ast.Node]types.Object{},
+			Instances:  map[*ast.Ident]types.Instance{},
+			Scopes:     map[ast.Node]*types.Scope{},
+			Selections: map[*ast.SelectorExpr]*types.Selection{},
+		},
+	}
+	var missing error
+	cfg := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if p, ok := imported[path]; ok {
+				return p, nil
+			}
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			missing = fmt.Errorf("%q is not loaded", path)
+			return nil, missing
+		}),
+		Error: func(err error) {
+			info.Errors = append(info.Errors, err)
+		},
+	}
+	info.Pkg, _ = cfg.Check(v.imp, fs, files, &info.Info)
+	if missing != nil {
+		return nil, missing
+	}
+
+	//the package is replaced by a created package,
+	//so that prog.Package finds it by path instead of by the old package
+	prog := *v.prog
+	prog.Imported = map[string]*loader.PackageInfo{}
+	for k, p := range v.prog.Imported {
+		if p != v.pkg {
+			prog.Imported[k] = p
+		}
+	}
+	prog.AllPackages = map[*types.Package]*loader.PackageInfo{}
+	for k, p := range v.prog.AllPackages {
+		if p != v.pkg {
+			prog.AllPackages[k] = p
+		}
+	}
+	prog.AllPackages[info.Pkg] = info
+	prog.Created = []*loader.PackageInfo{info}
+
+	//the closed types of the dependencies are unchanged
+	cts := map[*types.Package][]closed.Type{}
+	for p, ts := range v.closed {
+		if p != v.pkg.Pkg {
+			cts[p] = ts
+		}
+	}
+
+	return &view{
+		imp:    v.imp,
+		prog:   &prog,
+		pkg:    info,
+		closed: cts,
+	}, nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+//has reports whether filename is one of the files of the view's package.
+func (v *view) has(filename string) bool {
+	_, err := v.file(filename)
+	return err == nil
+}
+
+//file returns the syntax of filename in the view's package.
+func (v *view) file(filename string) (*ast.File, error) {
+	for _, f := range v.pkg.Files {
+		if filepath.Clean(v.prog.Fset.File(f.Pos()).Name()) == filepath.Clean(filename) {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not in %q", filename, v.imp)
+}
+
+//closedTypes of the view's package.
+func (v *view) closedTypes() ([]closed.Type, error) {
+	return v.closedTypesIn(v.pkg)
+}
+
+//closedTypesIn pkg, a package in the view,
+//extracting them only the first time.
+//
+//It is the fill.Lookup of the view.
+func (v *view) closedTypesIn(pkg *loader.PackageInfo) ([]closed.Type, error) {
+	if ts, ok := v.closed[pkg.Pkg]; ok {
+		return ts, nil
+	}
+	ts, err := closed.InPackage(v.prog.Fset, pkg.Files, pkg.Pkg)
+	if err := closedutil.Fatal(err); err != nil {
+		return nil, err
+	}
+	v.closed[pkg.Pkg] = ts
+	return ts, nil
+}
@@ -5,13 +5,12 @@ package main
 import (
 	"flag"
 	"fmt"
-	"go/ast"
 	"go/build"
 	"log"
 	"os"
-	"unicode"
-	"unicode/utf8"
 
+	"github.com/jimmyfrasche/closed/cmds/internal/cache"
+	"github.com/jimmyfrasche/closed/cmds/internal/gen"
 	"github.com/jimmyfrasche/closed/cmds/internal/tools"
 )
 
@@ -25,6 +24,7 @@ func main() {
 	log.SetFlags(0)
 
 	tools.AddTagsFlagDefault()
+	cache.AddFlagDefault()
 	var (
 		output = flag.String("o", "", "The `filename` to output")
 		method = flag.String("name", "", "The name to use for the func/method")
@@ -65,41 +65,32 @@ func main() {
 		failOn(err)
 	}
 
-	imports, impnames, err := computeImports(forPkg.ImportPath, T.T)
+	imports, impnames, err := gen.Imports(forPkg.ImportPath, T.T)
 	failOn(err)
 
 	filesBuildTags, err := tools.BuildTagsFrom(T.DefinedInFile)
 	failOn(err)
 
-	if mustFunc(T.T) {
+	if gen.MustFunc(T.T) {
 		*mkfunc = true
 	}
 	if *method == "" {
-		*method = "legal"
-
-		if *mkfunc {
-			name := T.Name
-			if !ast.IsExported(name) {
-				r, sz := utf8.DecodeRuneInString(name)
-				name = name[sz:]
-				r = unicode.ToUpper(r)
-				name = string(r) + name
-			}
-			*method = fmt.Sprintf("legal%s", name)
-		}
+		*method = gen.ValidatorName(T.Name, *mkfunc)
 	}
 	if *output == "" {
-		prefix := T.DefinedInFile[:len(*output)-3] //strip off ".go"
-		*output = fmt.Sprintf("%s_%s", prefix, os.Args[0])
+		prefix := T.DefinedInFile[:len(T.DefinedInFile)-3] //strip off ".go"
+		*output = fmt.Sprintf("%s_%s.go", prefix, os.Args[0])
 	}
 
 	err = tools.OverwriteCheck(*output, os.Args[0])
 	failOn(err)
 
-	g := &Generator{
+	g := &gen.Validator{
 		ToolName: os.Args[0],
 
-		T: T,
+		Name:       T.Name,
+		ImportPath: T.Pkg.ImportPath,
+		T:          T.T,
 
 		FName: *method,
 		Func:  *mkfunc,
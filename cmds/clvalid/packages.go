@@ -2,10 +2,7 @@ package main
 
 import (
 	"fmt"
-	"sort"
 
-	"github.com/jimmyfrasche/closed"
-	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
 	"github.com/jimmyfrasche/closed/cmds/internal/tools"
 )
 
@@ -63,29 +60,3 @@ func packages(buildTags []string, args []string) (typeName string, forPkg, fromP
 
 	return args[1], forPkg, fromPkg, nil
 }
-
-//computeImports computes a stable set of local aliases
-//for importing into the generated code and a map of import paths
-//to these local aliases.
-func computeImports(here string, c closed.Type) (imports []string, import2name map[string]string, err error) {
-	impset, err := closedutil.ImportsOf(c)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	sorted := make([]string, 0, len(impset))
-	for k := range impset {
-		if k == here {
-			continue
-		}
-		sorted = append(sorted, k)
-	}
-	sort.Strings(sorted)
-
-	out := make(map[string]string, len(sorted))
-	for i, v := range sorted {
-		out[v] = fmt.Sprintf("pkg%d", i)
-		imports = append(imports, fmt.Sprintf("pkg%d %q", i, v))
-	}
-	return imports, out, nil
-}
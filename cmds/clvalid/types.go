@@ -10,6 +10,7 @@ import (
 	"go/types"
 
 	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/cache"
 	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
 	"github.com/jimmyfrasche/closed/cmds/internal/tools"
 )
@@ -72,28 +73,19 @@ func LoadType(importPath, typeName string) (*Type, error) {
 	}
 	T.DefinedInFile = pos.Filename
 
-	ts, err := closed.InPackage(T.FileSet, T.Files, T.Types)
-	if err != nil {
+	ts, err := cache.InPackage(T.FileSet, T.Files, T.Types)
+	if err := closedutil.Fatal(err); err != nil {
 		return nil, err
 	}
 
 	T.T = closedutil.Find(typ, ts)
 	if T.T == nil {
-		return nil, fmt.Errorf("%s is not recognized as a closed type", T.Name)
+		return nil, fmt.Errorf("%s is not recognized as a closed type: %s", T.Name, closedutil.WhyNot(T.FileSet, T.Files, typ))
 	}
 
 	return T, nil
 }
 
-//mustFunc returns true if c does not allow methods.
-func mustFunc(c closed.Type) bool {
-	switch c.(type) {
-	case *closed.Interface, *closed.EmptySum:
-		return true
-	}
-	return false
-}
-
 //externalOkay ensures T can be validated outside its defining package.
 func externalOkay(T *Type) error {
 	//T is from another package, need to make sure we have access to it.
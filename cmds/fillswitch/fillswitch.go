@@ -2,26 +2,35 @@
 //when the expression switched is a *closed.Enum, *closed.Interface,
 //or *closed.EmptySum.
 //
+//Tagless switches and if/else-if chains whose conditions all compare
+//the same expression of a *closed.Enum type against its labels,
+//such as
+//	switch {
+//	case x == A:
+//	case x == B || x == C:
+//	}
+//are also filled in, with the missing comparisons added.
+//Unlike a switch, an if/else-if chain is not given a final else.
+//
+//With -gen, the cursor may instead be on an expression of closed type
+//and a complete switch over that expression is inserted as a new statement.
+//
 //It is intended to be integrated into an editor.
 package main
 
 import (
 	"flag"
 	"fmt"
-	"go/ast"
 	"go/build"
 	"go/format"
-	"go/token"
-	"go/types"
 	"log"
 	"os"
 
-	"github.com/jimmyfrasche/closed"
-	"github.com/jimmyfrasche/closed/cmds/fillswitch/internal/guess"
-	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+	"github.com/jimmyfrasche/closed/cmds/internal/cache"
+	"github.com/jimmyfrasche/closed/cmds/internal/fill"
+	"github.com/jimmyfrasche/closed/cmds/internal/guess"
 	"github.com/jimmyfrasche/closed/cmds/internal/tools"
 	"golang.org/x/tools/go/buildutil"
-	"golang.org/x/tools/go/loader"
 )
 
 func failOn(err error) {
@@ -35,17 +44,21 @@ func main() {
 	log.SetFlags(0)
 
 	tools.AddTagsFlagDefault()
+	cache.AddFlagDefault()
 	var (
 		modified, save, flat bool
+		gen                  bool
 		offset, line         int
-		imp                  string
+		imp, sortBy          string
 	)
 	flag.BoolVar(&modified, "modified", false, "read `archive` of modified files from stdin")
 	flag.BoolVar(&save, "w", false, "write result to file, instead of stdout")
 	flag.BoolVar(&flat, "flat", false, "output as a single case")
+	flag.BoolVar(&gen, "gen", false, "generate a new switch over the expression of closed type at -offset")
 	flag.IntVar(&offset, "offset", 0, "byte offset of `cursor position` inside switch statement")
 	flag.IntVar(&line, "line", 0, "line number inside switch statement")
 	flag.StringVar(&imp, "import", "", "import path of package containing -file")
+	flag.StringVar(&sortBy, "sort", "decl", "`order` of cases: decl, alpha, or value")
 
 	flag.Usage = func() {
 		log.SetPrefix("")
@@ -75,6 +88,11 @@ func main() {
 	if offset != 0 && line != 0 {
 		log.Fatal("cannot specify both -offset and -line")
 	}
+	if gen && offset == 0 {
+		log.Fatal("-gen requires -offset")
+	}
+	mode, err := fill.ParseSortMode(sortBy)
+	failOn(err)
 
 	bc := &build.Default
 	if modified {
@@ -83,6 +101,7 @@ func main() {
 
 		if len(overlay) > 0 {
 			bc = buildutil.OverlayContext(bc, overlay)
+			cache.Context = bc
 		}
 	}
 
@@ -92,139 +111,25 @@ func main() {
 		failOn(err)
 	}
 
-	fs, astf, sw, pkg, dpkg, prog, ct, err := extractSwitchAndTypeInfo(bc, imp, file, line, offset)
-	failOn(err)
-
-	//we need to do this even if no imports are added in order to find
-	//out what the local names of packages are in astf
-	//as there may be local aliases
-	imps, err := addImportsAndGetLocalPackageNames(fs, astf, ct, pkg, dpkg, sw, prog)
-	failOn(err)
-
-	cases, defaultCase, err := computeCasesToAdd(sw, ct, pkg, dpkg, imps)
+	prog, err := load(bc, imp)
 	failOn(err)
 
-	clauses := toCaseClauses(cases, flat)
-	sw = spliceClauses(sw, clauses, defaultCase)
-
-	format.Node(os.Stdout, fs, astf)
-}
-
-func extractSwitchAndTypeInfo(bc *build.Context, imp, file string, line, offset int) (fs *token.FileSet, f *ast.File, sw ast.Stmt, cpkg, dpkg *loader.PackageInfo, prog *loader.Program, ct closed.Type, err error) {
-	fail := func(err error) (fs *token.FileSet, f *ast.File, sw ast.Stmt, ckpg, dpkg *loader.PackageInfo, prog *loader.Program, ct closed.Type, e error) {
-		return nil, nil, nil, nil, nil, nil, nil, err
-	}
-
-	prog, err = load(bc, imp)
-	if err != nil {
-		return fail(err)
-	}
-
 	pkg, astf, err := pkgWithFile(imp, file, prog)
-	if err != nil {
-		return fail(err)
-	}
-
-	switches := switchesOf(astf)
-	if len(switches) == 0 {
-		return fail(fmt.Errorf("no switches founds in %s", imp))
-	}
-
-	theSwitch, err := findSwitch(prog.Fset, astf, line, offset, switches)
-	if err != nil {
-		return fail(err)
-	}
-
-	st, err := typeOf(theSwitch, pkg.Info)
-	if err != nil {
-		return fail(err)
-	}
-
-	nt, err := typeNameOf(st)
-	if err != nil {
-		return fail(err)
-	}
-
-	dpkg, err = definingPackage(nt, prog)
-	if err != nil {
-		return fail(err)
-	}
-
-	ct, err = getClosed(nt, prog.Fset, dpkg)
-	if err != nil {
-		return fail(err)
-	}
-
-	return prog.Fset, astf, theSwitch, pkg, dpkg, prog, ct, nil
-}
-
-func computeCasesToAdd(sw ast.Stmt, ct closed.Type, pkg, dpkg *loader.PackageInfo, imps importMap) (cases []ast.Expr, defaultCase *ast.CaseClause, err error) {
-	fail := func(err error) ([]ast.Expr, *ast.CaseClause, error) {
-		return nil, nil, err
-	}
-
-	block, isTypeSwitch := body(sw)
-	used, noDefault := usedBy(block, pkg.Info.Types)
-	if noDefault {
-		defaultCase = mkDefault()
-	}
-
-	diffPkgs := pkg.Pkg != dpkg.Pkg
-
-	if isTypeSwitch {
-		var unused []types.Type
-		addNil := false
-
-		switch ct := ct.(type) {
-		case *closed.Interface:
-			unused, addNil = missingInterfaceCases(ct, used, diffPkgs)
-
-		case *closed.EmptySum:
-			unused, addNil = missingEmptyCases(ct, used, pkg.Pkg)
-
-		default:
-			return fail(fmt.Errorf("internal error: unexpected %T for type switch", ct))
-		}
-
-		if addNil {
-			cases = append(cases, mkNil())
-		}
+	failOn(err)
 
-		tp := newTypeSerializer(pkg.Pkg, imps)
-		for _, u := range unused {
-			x, err := tp.print(u)
-			if err != nil {
-				return fail(err)
-			}
-			cases = append(cases, x)
-		}
+	var sw *fill.Switch
+	if gen {
+		sw, err = fill.New(prog, pkg, astf, offset, nil)
 	} else {
-		enum, ok := ct.(*closed.Enum)
-		if !ok {
-			return fail(fmt.Errorf("internal error: unexpected %T for regular switch", ct))
-		}
-
-		unused, addZero, kind := missingEnumCases(enum, used, diffPkgs)
-
-		if addZero {
-			cases = append(cases, mkZero(kind))
-		}
-
-		pkgname := ""
-		if diffPkgs {
-			pkgname = imps.Name(dpkg.Pkg)
-		}
-
-		for _, u := range unused {
-			//like with closed.Interface, prefer exported labels
-			lbl := closedutil.FirstExportedLabel(u)
-			if lbl == nil {
-				lbl = u[0]
-			}
-
-			cases = append(cases, mkLabel(lbl.Name(), pkgname))
-		}
+		sw, err = fill.At(prog, pkg, astf, line, offset, nil)
 	}
+	failOn(err)
+
+	err = sw.Fill(fill.Options{
+		Flat: flat,
+		Sort: mode,
+	})
+	failOn(err)
 
-	return cases, defaultCase, nil
+	format.Node(os.Stdout, prog.Fset, astf)
 }
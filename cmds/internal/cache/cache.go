@@ -0,0 +1,428 @@
+//Package cache stores the closed types of packages on disk,
+//so that commands need not extract them again
+//until the package, or a package it imports, changes.
+//
+//Entries are keyed by a hash of the files of the package,
+//the build tags, the keys of its imports, computed in the same way,
+//and the executable, since a new version of closed may find different closed types.
+//As a key only depends on source, an entry can be found
+//without type checking the package or its imports.
+//The packages in GOROOT are assumed to only change with the version of Go.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jimmyfrasche/closed"
+	"golang.org/x/tools/go/buildutil"
+)
+
+//Mode is how the cache is used.
+type Mode int
+
+const (
+	//Off does not use the cache.
+	Off Mode = iota
+	//ReadOnly uses the cache but never adds to it.
+	ReadOnly
+	//ReadWrite uses the cache and adds to it.
+	ReadWrite
+)
+
+var modeNames = [...]string{
+	Off:       "off",
+	ReadOnly:  "ro",
+	ReadWrite: "rw",
+}
+
+func (m Mode) String() string {
+	if m < 0 || int(m) >= len(modeNames) {
+		return fmt.Sprintf("Mode(%d)", m)
+	}
+	return modeNames[m]
+}
+
+//Set implements flag.Value.
+func (m *Mode) Set(s string) error {
+	for i, nm := range modeNames {
+		if nm == s {
+			*m = Mode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid cache mode %q: must be off, ro, or rw", s)
+}
+
+var (
+	//Default is the Mode used by InPackage and Open.
+	Default = Off
+
+	//Context is used to read the files of packages,
+	//so that it sees the same files as the loader when overlaid.
+	Context = &build.Default
+
+	//Dir is the directory holding the cache.
+	//If empty, it is closed in the user cache directory.
+	Dir string
+)
+
+//AddFlag registers the -cache flag for m in fs.
+func AddFlag(fs *flag.FlagSet, m *Mode) {
+	fs.Var(m, "cache", "`mode` of the on-disk cache of closed types: off, ro, or rw")
+}
+
+//AddFlagDefault calls AddFlag with the default flag set and Mode.
+func AddFlagDefault() {
+	AddFlag(flag.CommandLine, &Default)
+}
+
+func dir() (string, error) {
+	if Dir != "" {
+		return Dir, nil
+	}
+	d, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "closed"), nil
+}
+
+//An Entry is the location in the cache of the closed types of a package.
+//
+//A nil *Entry is valid and is always missing from the cache.
+type Entry struct {
+	mode Mode
+	file string
+}
+
+//A Session remembers the key of each import, by import path and source directory,
+//so that each is computed once however many packages of one load import it.
+//
+//An import edited after its key is computed keeps that key,
+//so a Session should not outlive the load it is used for.
+type Session struct {
+	mu   sync.Mutex
+	keys map[[2]string]string
+}
+
+//NewSession returns a Session that has not computed any keys.
+func NewSession() *Session {
+	return &Session{
+		keys: map[[2]string]string{},
+	}
+}
+
+//Open the Entry for the package with the import path imp
+//made of the named files.
+//
+//If the cache is off or the package cannot be hashed, nil is returned.
+func Open(imp string, filenames []string) *Entry {
+	return NewSession().Open(imp, filenames)
+}
+
+//OpenImport is Open for the package imp as found by Context from the current directory.
+func OpenImport(imp string) *Entry {
+	return NewSession().OpenImport(imp)
+}
+
+//Open is Open using the keys of imports remembered by s.
+func (s *Session) Open(imp string, filenames []string) *Entry {
+	if Default == Off {
+		return nil
+	}
+	d, err := dir()
+	if err != nil {
+		return nil
+	}
+	key, err := s.hash(imp, filenames)
+	if err != nil {
+		return nil
+	}
+	return &Entry{
+		mode: Default,
+		file: filepath.Join(d, key[:2], key+".json"),
+	}
+}
+
+//OpenImport is OpenImport using the keys of imports remembered by s.
+func (s *Session) OpenImport(imp string) *Entry {
+	if Default == Off {
+		return nil
+	}
+	bi, err := Context.Import(imp, ".", 0)
+	if err != nil {
+		return nil
+	}
+	return s.Open(bi.ImportPath, filesOf(bi))
+}
+
+//Portables gets the descriptions of the closed types from the cache,
+//reporting whether they were found.
+//
+//Unlike Get, the package need not be type checked.
+func (e *Entry) Portables() ([]*closed.Portable, bool) {
+	if e == nil {
+		return nil, false
+	}
+	bs, err := ioutil.ReadFile(e.file)
+	if err != nil {
+		return nil, false
+	}
+	var ps []*closed.Portable
+	if err := json.Unmarshal(bs, &ps); err != nil {
+		return nil, false
+	}
+	return ps, true
+}
+
+//Get the closed types of pkg from the cache, reporting whether they were found.
+func (e *Entry) Get(pkg *types.Package) ([]closed.Type, bool) {
+	ps, ok := e.Portables()
+	if !ok {
+		return nil, false
+	}
+	ts := make([]closed.Type, 0, len(ps))
+	for _, p := range ps {
+		t, err := p.Resolve(pkg)
+		if err != nil {
+			return nil, false
+		}
+		ts = append(ts, t)
+	}
+	return ts, true
+}
+
+//Put the closed types in the cache, if it is writable.
+//
+//The cache is best effort, so failures are ignored.
+func (e *Entry) Put(ts []closed.Type) {
+	if e == nil || e.mode != ReadWrite {
+		return
+	}
+	ps := make([]*closed.Portable, 0, len(ts))
+	for _, t := range ts {
+		p, err := closed.ToPortable(t)
+		if err != nil {
+			return
+		}
+		ps = append(ps, p)
+	}
+	bs, err := json.Marshal(ps)
+	if err != nil {
+		return
+	}
+
+	//write then rename, so that a concurrent Get never sees part of a file
+	if err := os.MkdirAll(filepath.Dir(e.file), 0777); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(filepath.Dir(e.file), "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(bs)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), e.file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+//InPackage is closed.InPackage, using the cache as set by Default.
+//
+//Partial results are not cached, so that their errors are reported every time.
+func InPackage(fs *token.FileSet, files []*ast.File, pkg *types.Package) ([]closed.Type, error) {
+	var e *Entry
+	if Default != Off {
+		names := make([]string, len(files))
+		for i, f := range files {
+			names[i] = fs.File(f.Pos()).Name()
+		}
+		e = Open(pkg.Path(), names)
+	}
+	if ts, ok := e.Get(pkg); ok {
+		return ts, nil
+	}
+	ts, err := closed.InPackage(fs, files, pkg)
+	if err == nil {
+		e.Put(ts)
+	}
+	return ts, err
+}
+
+//hash the inputs to extracting the closed types of the package imp made of filenames.
+func (s *Session) hash(imp string, filenames []string) (string, error) {
+	h := sha256.New()
+	field := func(s string) {
+		fmt.Fprintf(h, "%d:%s\n", len(s), s)
+	}
+
+	field("closed cache 3")
+	if err := hashExecutable(h); err != nil {
+		return "", err
+	}
+	field(runtime.Version())
+	field(Context.GOOS)
+	field(Context.GOARCH)
+	tags := append([]string(nil), Context.BuildTags...)
+	sort.Strings(tags)
+	for _, t := range tags {
+		field(t)
+	}
+
+	if err := s.hashSource(h, imp, filenames); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//hashSource writes the files of the package imp to w,
+//followed by the keys of its imports.
+func (s *Session) hashSource(w io.Writer, imp string, filenames []string) error {
+	if len(filenames) == 0 {
+		return fmt.Errorf("%s has no files", imp)
+	}
+	field := func(s string) {
+		fmt.Fprintf(w, "%d:%s\n", len(s), s)
+	}
+
+	field(imp)
+	names := append([]string(nil), filenames...)
+	sort.Strings(names)
+	imports := map[string]bool{}
+	for _, nm := range names {
+		field(nm)
+		if err := hashFile(w, nm, imports); err != nil {
+			return err
+		}
+	}
+
+	//the key of an import stands in for its export data,
+	//and includes the keys of its own imports
+	var paths []string
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		field(p)
+		k, err := s.keyOf(p, filepath.Dir(names[0]))
+		if err != nil {
+			return err
+		}
+		field(k)
+	}
+	return nil
+}
+
+//keyOf the package imported as path by a file in srcDir.
+func (s *Session) keyOf(path, srcDir string) (string, error) {
+	//unsafe and cgo have no source
+	if path == "unsafe" || path == "C" {
+		return "", nil
+	}
+
+	id := [2]string{path, srcDir}
+	s.mu.Lock()
+	k, ok := s.keys[id]
+	s.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+
+	bi, err := Context.Import(path, srcDir, 0)
+	if err != nil {
+		return "", err
+	}
+	if bi.Goroot {
+		k = "goroot"
+	} else {
+		h := sha256.New()
+		if err := s.hashSource(h, bi.ImportPath, filesOf(bi)); err != nil {
+			return "", err
+		}
+		k = hex.EncodeToString(h.Sum(nil))
+	}
+
+	s.mu.Lock()
+	s.keys[id] = k
+	s.mu.Unlock()
+	return k, nil
+}
+
+//filesOf bi that are type checked.
+func filesOf(bi *build.Package) []string {
+	var acc []string
+	for _, nms := range [][]string{bi.GoFiles, bi.CgoFiles} {
+		for _, nm := range nms {
+			acc = append(acc, filepath.Join(bi.Dir, nm))
+		}
+	}
+	return acc
+}
+
+//hashFile writes the contents of the named file to w
+//and adds the paths it imports to imports.
+func hashFile(w io.Writer, name string, imports map[string]bool) error {
+	f, err := buildutil.OpenFile(Context, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	src, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(src); err != nil {
+		return err
+	}
+
+	af, err := parser.ParseFile(token.NewFileSet(), name, src, parser.ImportsOnly)
+	if err != nil {
+		return err
+	}
+	for _, is := range af.Imports {
+		p, err := strconv.Unquote(is.Path.Value)
+		if err != nil {
+			return err
+		}
+		imports[p] = true
+	}
+	return nil
+}
+
+//hashExecutable identifies the running command by its path, size and modification time,
+//which is much faster than hashing its contents.
+func hashExecutable(w io.Writer) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s %d %d\n", exe, fi.Size(), fi.ModTime().UnixNano())
+	return err
+}
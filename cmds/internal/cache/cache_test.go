@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jimmyfrasche/closed"
+)
+
+const src = `package p
+
+import "go/ast"
+
+type E int
+
+const (
+	A E = iota
+	B
+)
+
+type S interface {
+	ast.Node
+	isS()
+}
+
+type X struct{ ast.Ident }
+
+func (X) isS() {}
+`
+
+func check(t *testing.T, dir, src string) (*token.FileSet, []*ast.File, *types.Package) {
+	t.Helper()
+	name := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(name, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, name, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []*ast.File{f}
+	cfg := types.Config{
+		Importer: importer.ForCompiler(fs, "source", nil),
+	}
+	pkg, err := cfg.Check("p", fs, files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs, files, pkg
+}
+
+func portable(t *testing.T, ts []closed.Type) string {
+	t.Helper()
+	var ps []*closed.Portable
+	for _, x := range ts {
+		p, err := closed.ToPortable(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps = append(ps, p)
+	}
+	bs, err := json.Marshal(ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bs)
+}
+
+//name of the file of the package checked in dir.
+func name(dir string) []string {
+	return []string{filepath.Join(dir, "p.go")}
+}
+
+func entries(t *testing.T) int {
+	t.Helper()
+	ms, err := filepath.Glob(filepath.Join(Dir, "*", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(ms)
+}
+
+func TestCache(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "closed-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	Dir = filepath.Join(tmp, "cache")
+	defer func() {
+		Dir, Default = "", Off
+	}()
+
+	fs, files, pkg := check(t, tmp, src)
+	want, err := closed.InPackage(fs, files, pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Default = Off
+	if Open("p", name(tmp)) != nil {
+		t.Fatal("opened an entry when the cache is off")
+	}
+
+	Default = ReadOnly
+	if _, err := InPackage(fs, files, pkg); err != nil {
+		t.Fatal(err)
+	}
+	if n := entries(t); n != 0 {
+		t.Fatalf("read only cache wrote %d entries", n)
+	}
+
+	Default = ReadWrite
+	if _, err := InPackage(fs, files, pkg); err != nil {
+		t.Fatal(err)
+	}
+	if n := entries(t); n != 1 {
+		t.Fatalf("got %d entries, want 1", n)
+	}
+
+	//the entry is found from the source alone
+	if _, ok := Open("p", name(tmp)).Portables(); !ok {
+		t.Fatal("not found in cache without type checking")
+	}
+
+	//reload the same source, so that only the cache can supply the same results
+	_, _, pkg = check(t, tmp, src)
+	for _, m := range []Mode{ReadOnly, ReadWrite} {
+		Default = m
+		got, ok := Open("p", name(tmp)).Get(pkg)
+		if !ok {
+			t.Fatalf("%s: not found in cache", m)
+		}
+		if g, w := portable(t, got), portable(t, want); g != w {
+			t.Errorf("%s: got %s\nwant %s", m, g, w)
+		}
+	}
+
+	//any change to the source is a different entry
+	_, _, pkg = check(t, tmp, src+"\nconst C E = 2\n")
+	if _, ok := Open("p", name(tmp)).Get(pkg); ok {
+		t.Error("found changed package in cache")
+	}
+}
+
+func TestKeyImports(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "closed-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	Dir = filepath.Join(tmp, "cache")
+	defer func() {
+		Dir, Default, Context = "", Off, &build.Default
+	}()
+	Default = ReadOnly
+
+	t.Setenv("GO111MODULE", "off")
+	bc := build.Default
+	bc.GOPATH = tmp
+	Context = &bc
+
+	write := func(path, src string) []string {
+		t.Helper()
+		d := filepath.Join(tmp, "src", filepath.Dir(path))
+		if err := os.MkdirAll(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+		nm := filepath.Join(tmp, "src", path)
+		if err := ioutil.WriteFile(nm, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return []string{nm}
+	}
+	key := func(names []string) string {
+		t.Helper()
+		e := Open("p", names)
+		if e == nil {
+			t.Fatal("could not open entry")
+		}
+		return e.file
+	}
+
+	write("q/q.go", "package q\n\ntype T int\n")
+	p := write("p/p.go", "package p\n\nimport \"q\"\n\nvar _ q.T\n")
+	before := key(p)
+	if again := key(p); again != before {
+		t.Errorf("same source has keys %s and %s", before, again)
+	}
+	if e := OpenImport("p"); e == nil || e.file != before {
+		t.Errorf("OpenImport has a different key than Open")
+	}
+
+	s := NewSession()
+	if e := s.Open("p", p); e == nil || e.file != before {
+		t.Errorf("session has a different key than Open")
+	}
+
+	write("q/q.go", "package q\n\ntype T int\n\nconst A T = 1\n")
+	if key(p) == before {
+		t.Error("changing an import did not change the key")
+	}
+	//only a new session sees the change
+	if e := s.Open("p", p); e == nil || e.file != before {
+		t.Errorf("session did not remember the key of an import")
+	}
+}
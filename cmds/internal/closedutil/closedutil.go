@@ -2,6 +2,7 @@ package closedutil
 
 import (
 	"fmt"
+	"go/ast"
 	"go/constant"
 	"go/token"
 	"go/types"
@@ -9,6 +10,16 @@ import (
 	"github.com/jimmyfrasche/closed"
 )
 
+//Fatal returns err unless it is closed.Errors,
+//in which case the closed types returned with it
+//are all those that could be found and may be used.
+func Fatal(err error) error {
+	if _, ok := err.(closed.Errors); ok {
+		return nil
+	}
+	return err
+}
+
 //AlwaysValid returns true if t is always valid.
 //
 //This happens for bitsets with flags for each bit
@@ -328,3 +339,19 @@ func Find(t *types.TypeName, vs []closed.Type) closed.Type {
 	}
 	return nil
 }
+
+//WhyNot explains why t, defined in the package checked from files,
+//was not recognized as a closed type.
+func WhyNot(fs *token.FileSet, files []*ast.File, t *types.TypeName) string {
+	//explanations are by defined type, so see through any alias
+	if n, ok := types.Unalias(t.Type()).(*types.Named); ok {
+		t = n.Obj()
+	}
+	_, es, _ := closed.Explain(fs, files, t.Pkg())
+	for _, e := range es {
+		if e.Type == t {
+			return e.Reason
+		}
+	}
+	return fmt.Sprintf("%s is not a defined type in %s", t.Name(), t.Pkg().Path())
+}
@@ -1,4 +1,4 @@
-package main
+package fill
 
 import (
 	"bytes"
@@ -9,20 +9,38 @@ import (
 	"go/token"
 	"go/types"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
 )
 
-//switchesOf finds all switches and type switches in f.
-func switchesOf(f *ast.File) []ast.Stmt {
+//switchesOf finds all switches, type switches, and if/else-if chains in f.
+//
+//Since if statements are common, only chains that compare an expression
+//against constants in every condition are included.
+func switchesOf(f *ast.File, ti types.Info) []ast.Stmt {
 	var switches []ast.Stmt
+	elseIfs := map[*ast.IfStmt]bool{}
 	for _, d := range f.Decls {
 		ast.Inspect(d, func(n ast.Node) bool {
-			if s, ok := n.(*ast.SwitchStmt); ok {
-				//note that we could check if s.Tag == nil here,
+			switch s := n.(type) {
+			case *ast.SwitchStmt:
+				//note that we could check if s is a chain here,
 				//but we can produce error messages if we defer checking.
 				switches = append(switches, s)
-			} else if s, ok := n.(*ast.TypeSwitchStmt); ok {
+			case *ast.TypeSwitchStmt:
 				switches = append(switches, s)
+			case *ast.IfStmt:
+				//only the head of an if/else-if chain is a candidate
+				if e, ok := s.Else.(*ast.IfStmt); ok {
+					elseIfs[e] = true
+				}
+				if elseIfs[s] {
+					break
+				}
+				if _, err := subjectOf(s, ti); err == nil {
+					switches = append(switches, s)
+				}
 			}
 			return true
 		})
@@ -157,7 +175,36 @@ func (p *typeSerializer) qualify(pkg *types.Package) string {
 func (p *typeSerializer) print(t types.Type) (ast.Expr, error) {
 	p.buf.Reset()
 	types.WriteType(&p.buf, t, p.qualify)
-	return parser.ParseExpr(p.buf.String())
+	return parseExpr(p.buf.String())
+}
+
+//parseExpr parses the expression x without positions,
+//so that the printer does not lay it out
+//as if it were at the start of the file.
+func parseExpr(x string) (ast.Expr, error) {
+	e, err := parser.ParseExpr(x)
+	if err != nil {
+		return nil, err
+	}
+	clearPositions(e)
+	return e, nil
+}
+
+//clearPositions of every node in root.
+func clearPositions(root ast.Node) {
+	noPos := reflect.TypeOf(token.NoPos)
+	ast.Inspect(root, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		v := reflect.ValueOf(n).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.Type() == noPos {
+				f.SetInt(int64(token.NoPos))
+			}
+		}
+		return true
+	})
 }
 
 func body(sw ast.Stmt) (block *ast.BlockStmt, isTypeSwitch bool) {
@@ -167,7 +214,7 @@ func body(sw ast.Stmt) (block *ast.BlockStmt, isTypeSwitch bool) {
 	case *ast.TypeSwitchStmt:
 		return sw.Body, true
 	}
-	panic("unreachable")
+	panic("unreachable: body of chain")
 }
 
 func mkNil() ast.Expr {
@@ -208,64 +255,88 @@ func mkLabel(name, pkg string) ast.Expr {
 	}
 }
 
+//mkConversion returns the expression T(x).
+func mkConversion(T, x ast.Expr) ast.Expr {
+	return &ast.CallExpr{
+		Fun:  T,
+		Args: []ast.Expr{x},
+	}
+}
+
 func mkCase(xs ...ast.Expr) *ast.CaseClause {
 	return &ast.CaseClause{
 		List: xs,
 	}
 }
 
-func toCaseClauses(xs []ast.Expr, flat bool) []ast.Stmt {
+//toCaseClauses creates the case clauses for xs with the given ranks
+//and returns the rank of each clause.
+//
+//If flat, there is a single clause with xs in rank order.
+//If body is not empty, it is the statements of each clause.
+func toCaseClauses(xs []ast.Expr, ranks []int, flat bool, body []ast.Stmt) ([]ast.Stmt, []int) {
+	if len(xs) == 0 {
+		//a clause without expressions would be a second default
+		return nil, nil
+	}
 	if flat {
-		return []ast.Stmt{
-			mkCase(xs...),
+		idx := make([]int, len(xs))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.SliceStable(idx, func(i, j int) bool {
+			return ranks[idx[i]] < ranks[idx[j]]
+		})
+		sorted := make([]ast.Expr, len(xs))
+		for i, j := range idx {
+			sorted[i] = xs[j]
 		}
+
+		return []ast.Stmt{
+			withBody(mkCase(sorted...), body),
+		}, []int{ranks[idx[0]]}
 	}
 
 	cs := make([]ast.Stmt, 0, len(xs))
 	for _, x := range xs {
-		cs = append(cs, mkCase(x))
+		cs = append(cs, withBody(mkCase(x), body))
 	}
-	return cs
+	return cs, ranks
 }
 
-func spliceClauses(sw ast.Stmt, cases []ast.Stmt, defaultCase *ast.CaseClause) ast.Stmt {
+//withBody sets the body of c to body.
+//
+//The statements are shared by every clause, which is fine for printing.
+func withBody(c *ast.CaseClause, body []ast.Stmt) *ast.CaseClause {
+	if len(body) > 0 {
+		c.Body = body
+	}
+	return c
+}
+
+func spliceClauses(sw ast.Stmt, cases []ast.Stmt, ranks []int, defaultCase *ast.CaseClause, r *ranker) ast.Stmt {
 	if len(cases) == 0 && defaultCase == nil {
 		return sw
 	}
 	switch sw := sw.(type) {
 	case *ast.SwitchStmt:
-		sw.Body.List = addBlock(sw.Body.List, cases, defaultCase)
+		sw.Body.List = addBlock(sw.Body.List, cases, ranks, defaultCase, r)
 	case *ast.TypeSwitchStmt:
-		sw.Body.List = addBlock(sw.Body.List, cases, defaultCase)
+		sw.Body.List = addBlock(sw.Body.List, cases, ranks, defaultCase, r)
+	case *ast.IfStmt:
+		addElseIfs(sw, cases, ranks, r)
 	}
 	return sw
 }
 
-func addBlock(block []ast.Stmt, cases []ast.Stmt, defaultCase *ast.CaseClause) []ast.Stmt {
-	//no existing cases to worry about
-	if len(block) == 0 {
-		if defaultCase != nil {
-			cases = append(cases, defaultCase)
-		}
-		return cases
-	}
-
-	//If the first clause is a default, insert after it
-	insertAt := 0
-	if len(block) > 0 {
-		if c, ok := block[0].(*ast.CaseClause); ok && c.List == nil {
-			insertAt = 1
-		}
-	}
-
-	out := append([]ast.Stmt{}, block[:insertAt]...)
-	after := block[insertAt:]
-
-	out = append(out, cases...)
-	out = append(out, after...)
+//addBlock merges cases into block in rank order
+//and appends defaultCase, if any.
+func addBlock(block []ast.Stmt, cases []ast.Stmt, ranks []int, defaultCase *ast.CaseClause, r *ranker) []ast.Stmt {
+	out := merge(block, cases, ranks, func(s ast.Stmt) int {
+		return r.ofClause(s.(*ast.CaseClause))
+	})
 	if defaultCase != nil {
 		out = append(out, defaultCase)
 	}
-
 	return out
 }
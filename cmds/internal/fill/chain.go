@@ -0,0 +1,234 @@
+package fill
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/closed"
+)
+
+//A chain is a tagless switch or an if/else-if chain
+//where every condition compares the same expression of enum type
+//against its labels, like
+//	switch {
+//	case x == A:
+//	case x == B || x == C:
+//	}
+//or
+//	if x == A {
+//	} else if x == B || x == C {
+//	}
+//
+//isChain only reports whether s is filled as a chain, if it can be filled at all.
+//Whether its conditions are comparisons against labels
+//is checked by checkChain, once the closed type is known.
+func isChain(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.SwitchStmt:
+		return s.Tag == nil
+	case *ast.IfStmt:
+		return true
+	}
+	return false
+}
+
+//conditionsOf the chain s and whether it has a default case or final else.
+func conditionsOf(s ast.Stmt) (conds []ast.Expr, hasDefault bool) {
+	switch s := s.(type) {
+	case *ast.SwitchStmt:
+		for _, c := range s.Body.List {
+			c := c.(*ast.CaseClause)
+			if c.List == nil {
+				hasDefault = true
+			}
+			conds = append(conds, c.List...)
+		}
+
+	case *ast.IfStmt:
+		for {
+			conds = append(conds, s.Cond)
+			switch e := s.Else.(type) {
+			case *ast.IfStmt:
+				s = e
+				continue
+			case *ast.BlockStmt:
+				hasDefault = true
+			}
+			break
+		}
+	}
+	return conds, hasDefault
+}
+
+//splitComparison breaks x, an expression of the form
+//	s == L1 || L2 == s || (s == L3)
+//into its subjects and constant labels.
+func splitComparison(x ast.Expr, ti types.Info) (subjects, labels []ast.Expr, err error) {
+	switch b := ast.Unparen(x).(type) {
+	case *ast.BinaryExpr:
+		switch b.Op {
+		case token.LOR:
+			s1, l1, err := splitComparison(b.X, ti)
+			if err != nil {
+				return nil, nil, err
+			}
+			s2, l2, err := splitComparison(b.Y, ti)
+			if err != nil {
+				return nil, nil, err
+			}
+			return append(s1, s2...), append(l1, l2...), nil
+
+		case token.EQL:
+			xc := ti.Types[b.X].Value != nil
+			yc := ti.Types[b.Y].Value != nil
+			switch {
+			case xc && !yc:
+				return []ast.Expr{b.Y}, []ast.Expr{b.X}, nil
+			case yc && !xc:
+				return []ast.Expr{b.X}, []ast.Expr{b.Y}, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("%s does not compare an expression against constants", types.ExprString(x))
+}
+
+//chainInfo extracts the expression compared by every condition in the chain s,
+//the constants it is compared against,
+//and whether the chain lacks a default case or final else.
+func chainInfo(s ast.Stmt, ti types.Info) (subject ast.Expr, used []types.TypeAndValue, noDefault bool, err error) {
+	conds, hasDefault := conditionsOf(s)
+	if len(conds) == 0 {
+		return nil, nil, false, fmt.Errorf("switch must switch over expression or have cases comparing an expression against constants")
+	}
+
+	var subj string
+	for _, c := range conds {
+		subjects, labels, err := splitComparison(c, ti)
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		for _, x := range subjects {
+			str := types.ExprString(x)
+			if subject == nil {
+				subject, subj = x, str
+			} else if str != subj {
+				return nil, nil, false, fmt.Errorf("conditions compare both %s and %s", subj, str)
+			}
+		}
+
+		for _, L := range labels {
+			used = append(used, ti.Types[L])
+		}
+	}
+
+	return subject, used, !hasDefault, nil
+}
+
+//checkChain reports an error unless the chain s is over the enum ct
+//and every constant its conditions compare against is the value of a label.
+func checkChain(s ast.Stmt, ct closed.Type, ti types.Info) error {
+	e, ok := ct.(*closed.Enum)
+	if !ok {
+		return fmt.Errorf("%s is not an enum so its cases cannot be compared against labels", ct.Types()[0].Name())
+	}
+	_, used, _, err := chainInfo(s, ti)
+	if err != nil {
+		return err
+	}
+	for _, tv := range used {
+		if !hasLabel(e, tv.Value) {
+			return fmt.Errorf("%s is not the value of a label of %s", tv.Value, e.Types()[0].Name())
+		}
+	}
+	return nil
+}
+
+//hasLabel reports whether v is the value of a label of e.
+func hasLabel(e *closed.Enum, v constant.Value) bool {
+	for _, L := range e.Labels {
+		if ceq(L[0].Val(), v) {
+			return true
+		}
+	}
+	return false
+}
+
+//subjectOf returns the expression compared by every condition in the chain s.
+func subjectOf(s ast.Stmt, ti types.Info) (ast.Expr, error) {
+	subject, _, _, err := chainInfo(s, ti)
+	return subject, err
+}
+
+//mkCompare returns the expression subject == label.
+//
+//The subject is reparsed so that the result is free of positions.
+func mkCompare(subject, label ast.Expr) (ast.Expr, error) {
+	x, err := parseExpr(types.ExprString(subject))
+	if err != nil {
+		return nil, err
+	}
+	return &ast.BinaryExpr{
+		X:  x,
+		Op: token.EQL,
+		Y:  label,
+	}, nil
+}
+
+//mkOr joins xs with ||.
+func mkOr(xs []ast.Expr) ast.Expr {
+	acc := xs[0]
+	for _, x := range xs[1:] {
+		acc = &ast.BinaryExpr{
+			X:  acc,
+			Op: token.LOR,
+			Y:  x,
+		}
+	}
+	return acc
+}
+
+//addElseIfs merges an else-if for each case in cases into the chain s
+//in rank order, before the final else, if any.
+//The head of the chain always stays first.
+//
+//Unlike a switch, no final else is added when there is none,
+//as an empty else does nothing.
+func addElseIfs(s *ast.IfStmt, cases []ast.Stmt, ranks []int, r *ranker) {
+	var existing []ast.Stmt
+	last := s
+	for {
+		e, ok := last.Else.(*ast.IfStmt)
+		if !ok {
+			break
+		}
+		existing = append(existing, e)
+		last = e
+	}
+	final := last.Else
+
+	ifs := make([]ast.Stmt, len(cases))
+	for i, c := range cases {
+		c := c.(*ast.CaseClause)
+		ifs[i] = &ast.IfStmt{
+			Cond: mkOr(c.List),
+			Body: &ast.BlockStmt{List: c.Body},
+		}
+	}
+
+	chain := merge(existing, ifs, ranks, func(s ast.Stmt) int {
+		return r.ofExpr(s.(*ast.IfStmt).Cond)
+	})
+
+	last = s
+	for _, e := range chain {
+		e := e.(*ast.IfStmt)
+		last.Else = e
+		last = e
+	}
+
+	last.Else = final
+}
@@ -0,0 +1,370 @@
+//Package fill populates missing cases in switches over closed types.
+package fill
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/cache"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+	"golang.org/x/tools/go/loader"
+)
+
+//A Switch is a switch, type switch, or chain
+//over a closed type in a loaded program.
+type Switch struct {
+	//Stmt is the switch statement.
+	//If the Switch was created by New,
+	//it is not inserted into the file until Fill is called.
+	Stmt ast.Stmt
+	//Closed is the closed type of the switched expression.
+	Closed closed.Type
+
+	fs        *token.FileSet
+	file      *ast.File
+	pkg, dpkg *loader.PackageInfo
+	prog      *loader.Program
+	conv      types.Type
+
+	//at is the position used to determine what is in scope
+	at token.Pos
+	//insert adds a new Stmt to file.
+	insert func()
+}
+
+//A Lookup returns the closed types of a package in a program.
+//
+//A nil Lookup extracts them each time they are needed, using the cache.
+//Callers that find many switches in the same program,
+//such as an editor checking each change, can remember them instead.
+type Lookup func(*loader.PackageInfo) ([]closed.Type, error)
+
+func (l Lookup) in(fs *token.FileSet, pkg *loader.PackageInfo) ([]closed.Type, error) {
+	if l == nil {
+		return cache.InPackage(fs, pkg.Files, pkg.Pkg)
+	}
+	return l(pkg)
+}
+
+//Options control how a Switch is filled.
+type Options struct {
+	//Flat puts all missing cases in a single case.
+	Flat bool
+	//Sort is the order of the cases.
+	Sort SortMode
+	//Only, if not nil, limits the cases added to the labels or members
+	//it contains, and no default case or case for nil or the zero value is added.
+	//A label may be named by any of its synonyms.
+	//A member is named as it is written in the package defining the sum,
+	//such as T or *T.
+	Only []string
+	//Body, if not empty, is the statements of each case added,
+	//as returned by ParseBody.
+	Body []ast.Stmt
+}
+
+//ParseBody parses src, a list of Go statements, for Options.Body.
+//
+//The statements have no positions, so that they are laid out
+//where they are inserted.
+func ParseBody(src string) ([]ast.Stmt, error) {
+	if src == "" {
+		return nil, nil
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "body", "package p\nfunc _() {\n"+src+"\n}", 0)
+	if err != nil {
+		return nil, err
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	clearPositions(body)
+	return body.List, nil
+}
+
+//At finds the switch in f containing line or offset.
+//Only one of line or offset may be nonzero.
+//
+//The file f must be in pkg, a package in prog.
+//The closed types of the packages in prog are found by lookup.
+func At(prog *loader.Program, pkg *loader.PackageInfo, f *ast.File, line, offset int, lookup Lookup) (*Switch, error) {
+	var switches []ast.Stmt
+	for _, s := range switchesOf(f, pkg.Info) {
+		//if statements are common, so only those that are chains
+		//over a closed type are candidates
+		if _, ok := s.(*ast.IfStmt); ok {
+			if _, err := newSwitch(prog, pkg, f, s, lookup); err != nil {
+				continue
+			}
+		}
+		switches = append(switches, s)
+	}
+	if len(switches) == 0 {
+		return nil, fmt.Errorf("no switches founds in %s", pkg.Pkg.Path())
+	}
+
+	theSwitch, err := findSwitch(prog.Fset, f, line, offset, switches)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSwitch(prog, pkg, f, theSwitch, lookup)
+}
+
+//All returns every switch in f over a closed type
+//that does not use a chain.
+//Switches that cannot be filled are silently skipped.
+//The closed types of the packages in prog are found by lookup.
+func All(prog *loader.Program, pkg *loader.PackageInfo, f *ast.File, lookup Lookup) []*Switch {
+	var acc []*Switch
+	for _, s := range switchesOf(f, pkg.Info) {
+		if isChain(s) {
+			continue
+		}
+		sw, err := newSwitch(prog, pkg, f, s, lookup)
+		if err != nil {
+			continue
+		}
+		acc = append(acc, sw)
+	}
+	return acc
+}
+
+func newSwitch(prog *loader.Program, pkg *loader.PackageInfo, f *ast.File, s ast.Stmt, lookup Lookup) (*Switch, error) {
+	st, err := typeOf(s, pkg.Info)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, dpkg, conv, err := resolveClosed(st, prog, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	if isChain(s) {
+		if err := checkChain(s, ct, pkg.Info); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Switch{
+		Stmt:   s,
+		Closed: ct,
+		fs:     prog.Fset,
+		file:   f,
+		pkg:    pkg,
+		dpkg:   dpkg,
+		prog:   prog,
+		conv:   conv,
+		at:     s.Pos(),
+	}, nil
+}
+
+//Fill the missing cases of s, modifying the file containing s.
+//
+//Any imports required by the new cases are added to the file.
+func (s *Switch) Fill(opts Options) error {
+	if s.insert != nil {
+		s.insert()
+		s.insert = nil
+	}
+
+	//we need to do this even if no imports are added in order to find
+	//out what the local names of packages are in the file
+	//as there may be local aliases
+	imps, err := addImportsAndGetLocalPackageNames(s.fs, s.file, s.Closed, s.conv, s.pkg, s.dpkg, s.at, s.prog)
+	if err != nil {
+		return err
+	}
+
+	r := newRanker(s.pkg.Info, s.Closed, opts.Sort)
+
+	cases, ranks, defaultCase, err := computeCasesToAdd(s.Stmt, s.Closed, s.conv, s.pkg, s.dpkg, imps, r, opts.Only)
+	if err != nil {
+		return err
+	}
+
+	clauses, ranks := toCaseClauses(cases, ranks, opts.Flat, opts.Body)
+	s.Stmt = spliceClauses(s.Stmt, clauses, ranks, defaultCase, r)
+	return nil
+}
+
+//Missing returns the labels or members not handled by s,
+//as they would be written by Fill,
+//not including a default case or a case for nil or the zero value.
+//
+//Missing does not modify the file containing s.
+func (s *Switch) Missing() ([]string, error) {
+	imps := importMap(importsOfFile(s.file))
+	r := newRanker(s.pkg.Info, s.Closed, SortDecl)
+
+	cases, ranks, _, err := computeCasesToAdd(s.Stmt, s.Closed, s.conv, s.pkg, s.dpkg, imps, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var acc []string
+	for i, c := range cases {
+		if ranks[i] == zeroRank {
+			continue
+		}
+		acc = append(acc, types.ExprString(c))
+	}
+	return acc, nil
+}
+
+//computeCasesToAdd returns the cases missing from sw.
+//If only is not nil, only the cases it names are returned.
+func computeCasesToAdd(sw ast.Stmt, ct closed.Type, conv types.Type, pkg, dpkg *loader.PackageInfo, imps importMap, r *ranker, only []string) (cases []ast.Expr, ranks []int, defaultCase *ast.CaseClause, err error) {
+	fail := func(err error) ([]ast.Expr, []int, *ast.CaseClause, error) {
+		return nil, nil, nil, err
+	}
+
+	var (
+		used                    []types.TypeAndValue
+		noDefault, isTypeSwitch bool
+		subject                 ast.Expr
+	)
+	if isChain(sw) {
+		subject, used, noDefault, err = chainInfo(sw, pkg.Info)
+		if err != nil {
+			return fail(err)
+		}
+	} else {
+		var block *ast.BlockStmt
+		block, isTypeSwitch = body(sw)
+		used, noDefault = usedBy(block, pkg.Info.Types)
+	}
+	if noDefault && only == nil {
+		defaultCase = mkDefault()
+	}
+
+	diffPkgs := pkg.Pkg != dpkg.Pkg
+
+	if isTypeSwitch {
+		var unused []types.Type
+		addNil := false
+
+		switch ct := ct.(type) {
+		case *closed.Interface:
+			unused, addNil = missingInterfaceCases(ct, used, diffPkgs)
+
+		case *closed.EmptySum:
+			unused, addNil = missingEmptyCases(ct, used, pkg.Pkg)
+
+		default:
+			return fail(fmt.Errorf("internal error: unexpected %T for type switch", ct))
+		}
+
+		if only != nil {
+			unused = onlyMembers(unused, dpkg.Pkg, only)
+			addNil = false
+		}
+
+		if addNil {
+			cases = append(cases, mkNil())
+			ranks = append(ranks, zeroRank)
+		}
+
+		tp := newTypeSerializer(pkg.Pkg, imps)
+		for _, u := range unused {
+			x, err := tp.print(u)
+			if err != nil {
+				return fail(err)
+			}
+			cases = append(cases, x)
+			ranks = append(ranks, r.ofType(u))
+		}
+	} else {
+		enum, ok := ct.(*closed.Enum)
+		if !ok {
+			return fail(fmt.Errorf("internal error: unexpected %T for regular switch", ct))
+		}
+
+		unused, addZero, kind := missingEnumCases(enum, used, diffPkgs)
+		if only != nil {
+			unused = onlyLabels(unused, only)
+			addZero = false
+		}
+
+		if addZero {
+			cases = append(cases, mkZero(kind))
+			ranks = append(ranks, zeroRank)
+		}
+
+		pkgname := ""
+		if diffPkgs {
+			pkgname = imps.Name(dpkg.Pkg)
+		}
+
+		//the switch is over a type defined as enum so labels must be converted
+		var convT ast.Expr
+		if conv != nil {
+			convT, err = newTypeSerializer(pkg.Pkg, imps).print(conv)
+			if err != nil {
+				return fail(err)
+			}
+		}
+
+		for _, u := range unused {
+			//like with closed.Interface, prefer exported labels
+			lbl := closedutil.FirstExportedLabel(u)
+			if lbl == nil {
+				lbl = u[0]
+			}
+
+			x := mkLabel(lbl.Name(), pkgname)
+			if convT != nil {
+				x = mkConversion(convT, x)
+			}
+			cases = append(cases, x)
+			ranks = append(ranks, r.ofValue(u[0].Val()))
+		}
+	}
+
+	//chains need to spell out the comparison in each case
+	if subject != nil {
+		for i, c := range cases {
+			cases[i], err = mkCompare(subject, c)
+			if err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	return cases, ranks, defaultCase, nil
+}
+
+func onlyMembers(ms []types.Type, pkg *types.Package, only []string) []types.Type {
+	var acc []types.Type
+	for _, m := range ms {
+		if contains(only, types.TypeString(m, types.RelativeTo(pkg))) {
+			acc = append(acc, m)
+		}
+	}
+	return acc
+}
+
+func onlyLabels(ls [][]*types.Const, only []string) [][]*types.Const {
+	var acc [][]*types.Const
+	for _, L := range ls {
+		for _, c := range L {
+			if contains(only, c.Name()) {
+				acc = append(acc, L)
+				break
+			}
+		}
+	}
+	return acc
+}
+
+func contains(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package fill
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+//load the package a in testdata and its file named file.
+func load(t *testing.T, file string) (*loader.Program, *loader.PackageInfo, *ast.File) {
+	t.Helper()
+	gopath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GO111MODULE", "off")
+	bc := build.Default
+	bc.GOPATH = gopath
+
+	cfg := &loader.Config{
+		ParserMode: parser.ParseComments,
+		Build:      &bc,
+	}
+	cfg.Import("a")
+	prog, err := cfg.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("a")
+	for _, f := range pkg.Files {
+		if filepath.Base(prog.Fset.File(f.Pos()).Name()) == file {
+			return prog, pkg, f
+		}
+	}
+	t.Fatalf("no file %s in a", file)
+	return nil, nil, nil
+}
+
+//cursor returns the offset of the first occurrence of at in f.
+func cursor(t *testing.T, prog *loader.Program, f *ast.File, at string) int {
+	t.Helper()
+	src, err := os.ReadFile(prog.Fset.File(f.Pos()).Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := bytes.Index(src, []byte(at))
+	if offset < 0 {
+		t.Fatalf("%q not in %s", at, prog.Fset.File(f.Pos()).Name())
+	}
+	return offset
+}
+
+var fillTests = []struct {
+	name, file, at string
+	opts           Options
+}{
+	{"tagless", "tagless.go", "switch", Options{}},
+	{"ifchain", "ifchain.go", "if e == B", Options{}},
+	{"ifelse", "ifelse.go", "if e == A", Options{}},
+	{"notlabel", "notlabel.go", "e == 7", Options{}},
+	{"notlabel", "notlabel.go", "n == 3", Options{}},
+	{"mergedecl", "merge.go", "switch", Options{Sort: SortDecl}},
+	{"mergealpha", "merge.go", "switch", Options{Sort: SortAlpha}},
+	{"mergevalue", "merge.go", "switch", Options{Sort: SortValue}},
+	{"mergeflat", "merge.go", "switch", Options{Sort: SortValue, Flat: true}},
+	{"complete", "complete.go", "switch", Options{Flat: true}},
+	{"aliassum", "aliassum.go", "switch", Options{}},
+	{"aliasenum", "aliasenum.go", "switch", Options{}},
+	{"generic", "generic.go", "switch", Options{}},
+	{"defined", "defined.go", "switch", Options{}},
+	{"definedchain", "definedchain.go", "switch", Options{}},
+	{"definedsum", "definedsum.go", "switch", Options{}},
+	{"pointer", "pointer.go", "switch *p", Options{}},
+	{"mergesumdecl", "mergesum.go", "switch", Options{Sort: SortDecl}},
+	{"mergesumalpha", "mergesum.go", "switch", Options{Sort: SortAlpha}},
+}
+
+func TestFill(t *testing.T) {
+	for _, tc := range fillTests {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, pkg, f := load(t, tc.file)
+			s, err := At(prog, pkg, f, 0, cursor(t, prog, f, tc.at), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Fill(tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, prog, f, tc.name)
+		})
+	}
+}
+
+var newTests = []struct {
+	name, file, at string
+}{
+	{"genparam", "genparam.go", "e Enum"},
+	{"genuse", "genparam.go", "e, n"},
+	{"genvar", "genvar.go", "s b.Sum"},
+	{"genfield", "genfield.go", "Kind)"},
+	{"genmethod", "genmethod.go", "Sum()"},
+	{"genshadow", "genshadow.go", "B()"},
+	{"geninit", "geninit.go", "k != A"},
+	{"genelseif", "genelseif.go", "k != A"},
+	{"genrange", "genrange.go", "e := range"},
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range newTests {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, pkg, f := load(t, tc.file)
+			s, err := New(prog, pkg, f, cursor(t, prog, f, tc.at), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Fill(Options{}); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, prog, f, tc.name)
+		})
+	}
+}
+
+//golden compares f, printed, to testdata/src/a/name.golden.
+func golden(t *testing.T, prog *loader.Program, f *ast.File, name string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := format.Node(&buf, prog.Fset, f); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.Bytes()
+	want, err := os.ReadFile(filepath.Join("testdata", "src", "a", name+".golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+var errorTests = []struct {
+	file, at string
+	gen      bool
+	err      string
+}{
+	{"badchain.go", "switch", false, "7 is not the value of a label of Enum"},
+	{"pointer.go", "switch p", false, "*a.Enum is a pointer to a named type"},
+	{"pointer.go", "switch o", false, "Open is not recognized as a closed type"},
+	{"genswitch.go", "k {", true, "k is declared in the header of a switch"},
+	{"genswitch.go", "genSwitch", true, "no expression of closed type found"},
+}
+
+func TestFillErrors(t *testing.T) {
+	for _, tc := range errorTests {
+		prog, pkg, f := load(t, tc.file)
+		var err error
+		if tc.gen {
+			_, err = New(prog, pkg, f, cursor(t, prog, f, tc.at), nil)
+		} else {
+			_, err = At(prog, pkg, f, 0, cursor(t, prog, f, tc.at), nil)
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s at %q: got error %v, want %q", tc.file, tc.at, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package fill
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jimmyfrasche/closed"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/loader"
+)
+
+//New finds the expression of closed type at offset in f
+//and returns a new, empty switch over it.
+//
+//The file f must be in pkg, a package in prog.
+//The closed types of the packages in prog are found by lookup.
+//
+//The switch is inserted into f as a new statement when it is filled.
+func New(prog *loader.Program, pkg *loader.PackageInfo, f *ast.File, offset int, lookup Lookup) (*Switch, error) {
+	tf := prog.Fset.File(f.Pos())
+	if sz := tf.Size(); sz < offset {
+		return nil, fmt.Errorf("impossible offset %d: only %d bytes in %s", offset, sz, path.Base(tf.Name()))
+	}
+	pos := tf.Pos(offset)
+
+	p, _ := astutil.PathEnclosingInterval(f, pos, pos)
+
+	x, ct, dpkg, conv, err := closedExprOf(p, pkg, prog, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	list, at, err := insertionPoint(p, x, pkg.Info)
+	if err != nil {
+		return nil, err
+	}
+
+	sw, err := mkSwitch(x, ct, f, dpkg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Switch{
+		Stmt:   sw,
+		Closed: ct,
+		fs:     prog.Fset,
+		file:   f,
+		pkg:    pkg,
+		dpkg:   dpkg,
+		prog:   prog,
+		conv:   conv,
+		at:     pos,
+		insert: func() {
+			*list = append((*list)[:at], append([]ast.Stmt{sw}, (*list)[at:]...)...)
+		},
+	}, nil
+}
+
+//closedExprOf finds the innermost expression in p
+//whose type is a closed type that can be switched on.
+func closedExprOf(p []ast.Node, pkg *loader.PackageInfo, prog *loader.Program, lookup Lookup) (ast.Expr, closed.Type, *loader.PackageInfo, types.Type, error) {
+	var lastErr error
+	for i, n := range p {
+		x, ok := n.(ast.Expr)
+		if !ok {
+			continue
+		}
+		//in x.f, the expression is x.f not f
+		if i+1 < len(p) {
+			if s, ok := p[i+1].(*ast.SelectorExpr); ok && s.Sel == x {
+				continue
+			}
+		}
+		if !isVariable(x, pkg.Info) {
+			continue
+		}
+		t := pkg.Info.TypeOf(x)
+		if t == nil {
+			continue
+		}
+
+		if _, err := typeNameOf(t); err != nil {
+			continue
+		}
+
+		ct, dpkg, conv, err := resolveClosed(t, prog, lookup)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		return x, ct, dpkg, conv, nil
+	}
+
+	if lastErr != nil {
+		return nil, nil, nil, nil, lastErr
+	}
+	return nil, nil, nil, nil, errors.New("no expression of closed type found at cursor")
+}
+
+//isVariable reports whether x denotes a non-constant value
+//rather than a constant, type, or package.
+func isVariable(x ast.Expr, ti types.Info) bool {
+	if id, ok := x.(*ast.Ident); ok {
+		_, isVar := ti.ObjectOf(id).(*types.Var)
+		return isVar
+	}
+	tv, ok := ti.Types[x]
+	return ok && tv.IsValue() && tv.Value == nil
+}
+
+//insertionPoint returns the statement list where a switch
+//over x, an expression in p, should be inserted and the index to insert at.
+//
+//The switch goes after the innermost statement in p if it declares variables,
+//before it otherwise, and at the start of the body if the expression
+//is a parameter of a function.
+//If x uses a variable declared in the header of a statement,
+//such as the init of an if, the switch goes at the start of its body instead,
+//as the variable is not in scope before the statement.
+func insertionPoint(p []ast.Node, x ast.Expr, ti types.Info) (list *[]ast.Stmt, at int, err error) {
+	for i, n := range p[:len(p)-1] {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil {
+				return &n.Body.List, 0, nil
+			}
+		case *ast.FuncLit:
+			return &n.Body.List, 0, nil
+		}
+
+		s, ok := n.(ast.Stmt)
+		if !ok {
+			continue
+		}
+
+		if v := headerVarOf(s, x, ti); v != nil {
+			b := headerScope(s)
+			if b == nil {
+				return nil, 0, fmt.Errorf("%s is declared in the header of a switch, where a statement cannot be inserted", v.Name())
+			}
+			return &b.List, 0, nil
+		}
+
+		switch parent := p[i+1].(type) {
+		case *ast.BlockStmt:
+			list = &parent.List
+		case *ast.CaseClause:
+			list = &parent.Body
+		case *ast.CommClause:
+			list = &parent.Body
+		default:
+			continue
+		}
+
+		for j, s2 := range *list {
+			if s == s2 {
+				if isDefinition(s) {
+					j++
+				}
+				return list, j, nil
+			}
+		}
+	}
+	return nil, 0, errors.New("expression at cursor is not inside a statement")
+}
+
+//headerVarOf returns a variable used by x
+//that is declared in the header of s, if any.
+func headerVarOf(s ast.Stmt, x ast.Expr, ti types.Info) (v *types.Var) {
+	var from, to token.Pos
+	init := func(s ast.Stmt) {
+		if s != nil {
+			from, to = s.Pos(), s.End()
+		}
+	}
+	switch s := s.(type) {
+	case *ast.IfStmt:
+		init(s.Init)
+	case *ast.ForStmt:
+		init(s.Init)
+	case *ast.SwitchStmt:
+		init(s.Init)
+	case *ast.TypeSwitchStmt:
+		init(s.Init)
+	case *ast.RangeStmt:
+		if s.Tok == token.DEFINE {
+			from, to = s.Key.Pos(), s.X.Pos()
+		}
+	}
+	if !from.IsValid() {
+		return nil
+	}
+
+	ast.Inspect(x, func(n ast.Node) bool {
+		if v != nil {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			if obj, ok := ti.ObjectOf(id).(*types.Var); ok && from <= obj.Pos() && obj.Pos() < to {
+				v = obj
+			}
+		}
+		return true
+	})
+	return v
+}
+
+//headerScope returns the body of s, where the variables
+//declared in its header are in scope,
+//or nil if a statement cannot be inserted there.
+func headerScope(s ast.Stmt) *ast.BlockStmt {
+	switch s := s.(type) {
+	case *ast.IfStmt:
+		return s.Body
+	case *ast.ForStmt:
+		return s.Body
+	case *ast.RangeStmt:
+		return s.Body
+	}
+	return nil
+}
+
+//isDefinition reports whether s declares new variables.
+func isDefinition(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.AssignStmt:
+		return s.Tok == token.DEFINE
+	case *ast.DeclStmt:
+		return true
+	}
+	return false
+}
+
+//mkSwitch creates an empty switch over x
+//that is a type switch if ct is a sum.
+func mkSwitch(x ast.Expr, ct closed.Type, f *ast.File, dpkg *loader.PackageInfo) (ast.Stmt, error) {
+	//reparse x so the new switch is free of positions
+	tag, err := parseExpr(types.ExprString(x))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ct.(*closed.Enum); ok {
+		return &ast.SwitchStmt{
+			Tag:  tag,
+			Body: &ast.BlockStmt{},
+		}, nil
+	}
+
+	return &ast.TypeSwitchStmt{
+		Assign: &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent(bindingName(x, f, dpkg.Pkg))},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.TypeAssertExpr{X: tag}},
+		},
+		Body: &ast.BlockStmt{},
+	}, nil
+}
+
+//bindingName picks the name of the variable bound in a type switch over x.
+//
+//If x is a variable it is shadowed; if x is a field or method,
+//its name is used with the first letter lowered.
+//Otherwise, or if that name would shadow an import needed by the cases,
+//v is used.
+func bindingName(x ast.Expr, f *ast.File, dpkg *types.Package) string {
+	var nm string
+	switch x := ast.Unparen(x).(type) {
+	case *ast.Ident:
+		nm = x.Name
+	case *ast.SelectorExpr:
+		nm = lowerFirst(x.Sel.Name)
+	case *ast.CallExpr:
+		if s, ok := ast.Unparen(x.Fun).(*ast.SelectorExpr); ok {
+			nm = lowerFirst(s.Sel.Name)
+		}
+	}
+
+	if nm == "" || nm == "_" || token.IsKeyword(nm) || nm == dpkg.Name() {
+		return "v"
+	}
+	for p, local := range importsOfFile(f) {
+		if nm == local || (local == "" && nm == path.Base(p)) {
+			return "v"
+		}
+	}
+	return nm
+}
+
+func lowerFirst(s string) string {
+	r, sz := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[sz:]
+}
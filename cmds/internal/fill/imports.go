@@ -1,4 +1,4 @@
-package main
+package fill
 
 import (
 	"fmt"
@@ -27,17 +27,23 @@ func (i importMap) Name(p *types.Package) string {
 	}
 }
 
-func addImportsAndGetLocalPackageNames(fs *token.FileSet, f *ast.File, ct closed.Type, pkg, dpkg *loader.PackageInfo, sw ast.Stmt, prog *loader.Program) (importMap, error) {
+func addImportsAndGetLocalPackageNames(fs *token.FileSet, f *ast.File, ct closed.Type, conv types.Type, pkg, dpkg *loader.PackageInfo, at token.Pos, prog *loader.Program) (importMap, error) {
 	present := importMap(importsOfFile(f))
 	inT, err := closedutil.ImportsOf(ct)
 	if err != nil {
 		return nil, err
 	}
 
-	if pkg.Pkg == dpkg.Pkg {
-		delete(inT, pkg.Pkg.Path())
+	//labels must be converted to the type of the switch
+	if conv != nil {
+		if nm, ok := conv.(*types.Named); ok {
+			inT[nm.Obj().Pkg().Path()] = true
+		}
 	}
 
+	//never import the current package
+	delete(inT, pkg.Pkg.Path())
+
 	for p := range inT {
 		//import already exists
 		if _, ok := present[p]; ok {
@@ -49,11 +55,15 @@ func addImportsAndGetLocalPackageNames(fs *token.FileSet, f *ast.File, ct closed
 		return present, nil
 	}
 
-	s := dpkg.Info.Scopes[sw]
+	//the innermost scope in f containing at
+	s := pkg.Pkg.Scope().Innermost(at)
+	if s == nil {
+		s = pkg.Pkg.Scope()
+	}
 
 	for imp := range inT {
 		P := prog.Package(imp).Pkg.Name() //In all cases p must be in trans. deps. of dpkg
-		if s.Lookup(P) != nil {
+		if _, o := s.LookupParent(P, at); o != nil {
 			return nil, fmt.Errorf("cannot import %q, %s already in scope", imp, P)
 		}
 		if P == path.Base(imp) {
@@ -0,0 +1,215 @@
+package fill
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+)
+
+//A SortMode is the order in which cases are placed.
+type SortMode int
+
+const (
+	//SortDecl orders labels and members by their declaration.
+	SortDecl SortMode = iota
+	//SortAlpha orders labels and members by their name.
+	SortAlpha
+	//SortValue orders labels by their value.
+	//Members do not have values so they are ordered as in SortDecl.
+	SortValue
+)
+
+//ParseSortMode parses decl, alpha, or value into a SortMode.
+func ParseSortMode(s string) (SortMode, error) {
+	switch s {
+	case "decl":
+		return SortDecl, nil
+	case "alpha":
+		return SortAlpha, nil
+	case "value":
+		return SortValue, nil
+	}
+	return 0, fmt.Errorf("unknown sort %q: must be one of decl, alpha, or value", s)
+}
+
+//unranked is the rank of a case that is not a label or member,
+//such as default.
+const unranked = -2
+
+//zeroRank is the rank of nil or an unlabeled zero value,
+//which always go first.
+const zeroRank = -1
+
+//A ranker orders the cases of a switch over a closed type.
+//
+//Each label or member is assigned its index in the requested order.
+type ranker struct {
+	ti types.Info
+
+	//exactly one of these is set, according to the closed type.
+	labels  [][]*types.Const
+	members []types.Type
+}
+
+//newRanker ranks the labels or members of ct in mode order.
+//
+//closed gives labels and members in declaration order,
+//so SortDecl keeps them as they are.
+func newRanker(ti types.Info, ct closed.Type, mode SortMode) *ranker {
+	r := &ranker{ti: ti}
+
+	switch ct := ct.(type) {
+	case *closed.Enum:
+		r.labels = append(r.labels, ct.Labels...)
+		switch mode {
+		case SortAlpha:
+			sort.SliceStable(r.labels, func(i, j int) bool {
+				return labelName(r.labels[i]) < labelName(r.labels[j])
+			})
+		case SortValue:
+			sort.SliceStable(r.labels, func(i, j int) bool {
+				return constant.Compare(r.labels[i][0].Val(), token.LSS, r.labels[j][0].Val())
+			})
+		}
+
+	case *closed.Interface:
+		ms := append([]*closed.TypeNamesAndType{}, ct.Members...)
+		//members do not have values, so SortValue is SortDecl
+		if mode == SortAlpha {
+			sort.SliceStable(ms, func(i, j int) bool {
+				return memberName(ms[i]) < memberName(ms[j])
+			})
+		}
+		for _, m := range ms {
+			r.members = append(r.members, m.Type)
+		}
+
+	case *closed.EmptySum:
+		r.members = append(r.members, ct.Members...)
+		if mode == SortAlpha {
+			sort.SliceStable(r.members, func(i, j int) bool {
+				return types.TypeString(r.members[i], shortQualifier) < types.TypeString(r.members[j], shortQualifier)
+			})
+		}
+	}
+
+	return r
+}
+
+func shortQualifier(p *types.Package) string {
+	return p.Name()
+}
+
+//labelName is the name fillswitch uses for L.
+func labelName(L []*types.Const) string {
+	if l := closedutil.FirstExportedLabel(L); l != nil {
+		return l.Name()
+	}
+	return L[0].Name()
+}
+
+//memberName is the name fillswitch uses for m.
+func memberName(m *closed.TypeNamesAndType) string {
+	if t := closedutil.FirstExportedTypeName(m.TypeName); t != nil {
+		return t.Name()
+	}
+	return m.TypeName[0].Name()
+}
+
+//ofValue ranks the label with value v.
+func (r *ranker) ofValue(v constant.Value) int {
+	for i, L := range r.labels {
+		if ceq(L[0].Val(), v) {
+			return i
+		}
+	}
+	if ceq(v, czero(v.Kind())) {
+		return zeroRank
+	}
+	return unranked
+}
+
+//ofType ranks the member t.
+func (r *ranker) ofType(t types.Type) int {
+	for i, m := range r.members {
+		if types.Identical(m, t) {
+			return i
+		}
+	}
+	return unranked
+}
+
+//ofExpr ranks an expression in an existing case.
+func (r *ranker) ofExpr(x ast.Expr) int {
+	tv, ok := r.ti.Types[x]
+	switch {
+	case !ok:
+		return unranked
+	case tv.IsNil():
+		return zeroRank
+	case tv.Value != nil:
+		return r.ofValue(tv.Value)
+	case tv.IsType():
+		return r.ofType(tv.Type)
+	}
+
+	//a condition in a chain
+	_, labels, err := splitComparison(x, r.ti)
+	if err != nil {
+		return unranked
+	}
+	rank := unranked
+	for _, L := range labels {
+		if n := r.ofExpr(L); n != unranked && (rank == unranked || n < rank) {
+			rank = n
+		}
+	}
+	return rank
+}
+
+//ofClause ranks an existing case by its first ranked expression.
+func (r *ranker) ofClause(c *ast.CaseClause) int {
+	for _, x := range c.List {
+		if n := r.ofExpr(x); n != unranked {
+			return n
+		}
+	}
+	return unranked
+}
+
+//merge new cases into the existing cases in order of their rank.
+//
+//Existing cases are never reordered:
+//new cases are placed before the first existing case of greater rank.
+//Unranked existing cases, such as a default, are left where they are.
+func merge(existing, cases []ast.Stmt, ranks []int, rankOf func(ast.Stmt) int) []ast.Stmt {
+	idx := make([]int, len(cases))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return ranks[idx[i]] < ranks[idx[j]]
+	})
+
+	out := make([]ast.Stmt, 0, len(existing)+len(cases))
+	next := 0
+	for _, s := range existing {
+		if n := rankOf(s); n != unranked {
+			for next < len(idx) && ranks[idx[next]] < n {
+				out = append(out, cases[idx[next]])
+				next++
+			}
+		}
+		out = append(out, s)
+	}
+	for ; next < len(idx); next++ {
+		out = append(out, cases[idx[next]])
+	}
+	return out
+}
@@ -0,0 +1,10 @@
+package a
+
+import "b"
+
+type LocalAlias = b.EnumAlias
+
+func aliasEnum(k LocalAlias) {
+	switch k {
+	}
+}
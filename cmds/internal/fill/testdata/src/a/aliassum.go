@@ -0,0 +1,12 @@
+package a
+
+import "b"
+
+type Holder struct {
+	Sum b.SumAlias
+}
+
+func aliasSum(h Holder) {
+	switch h.Sum.(type) {
+	}
+}
@@ -0,0 +1,7 @@
+package a
+
+func badChain(e Enum) {
+	switch {
+	case e == 7:
+	}
+}
@@ -0,0 +1,7 @@
+package a
+
+func complete(e Enum) {
+	switch e {
+	case A, B, C, D:
+	}
+}
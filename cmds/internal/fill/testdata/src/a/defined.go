@@ -0,0 +1,11 @@
+package a
+
+import "b"
+
+type Local b.Enum
+
+func defined(l Local) {
+	switch l {
+	case Local(b.X):
+	}
+}
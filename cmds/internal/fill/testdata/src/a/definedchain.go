@@ -0,0 +1,8 @@
+package a
+
+type Chain Local
+
+func definedChain(c Chain) {
+	switch c {
+	}
+}
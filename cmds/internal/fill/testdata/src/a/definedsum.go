@@ -0,0 +1,10 @@
+package a
+
+import "b"
+
+type LocalSum b.Sum
+
+func definedSum(s LocalSum) {
+	switch s.(type) {
+	}
+}
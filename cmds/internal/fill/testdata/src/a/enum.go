@@ -0,0 +1,12 @@
+package a
+
+//Enum is declared out of order
+//so that declaration, alphabetical, and value order all differ.
+type Enum int
+
+const (
+	B Enum = 2
+	D Enum = 3
+	C Enum = 0
+	A Enum = 1
+)
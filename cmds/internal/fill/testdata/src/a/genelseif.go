@@ -0,0 +1,7 @@
+package a
+
+func genElseIf(f func() Enum) {
+	if f == nil {
+	} else if k := f(); k != A {
+	}
+}
@@ -0,0 +1,9 @@
+package a
+
+import "b"
+
+func generic(g b.GSum[int]) {
+	switch g.(type) {
+	case b.GB:
+	}
+}
@@ -0,0 +1,19 @@
+package a
+
+import "b"
+
+type T struct {
+	Kind b.Enum
+}
+
+func (T) Sum() b.Sum {
+	return nil
+}
+
+func (T) B() b.Sum {
+	return nil
+}
+
+func genField(t T) {
+	println(t.Kind)
+}
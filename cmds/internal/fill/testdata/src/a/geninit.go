@@ -0,0 +1,7 @@
+package a
+
+func genInit(f func() Enum) {
+	if k := f(); k != A {
+		println(k)
+	}
+}
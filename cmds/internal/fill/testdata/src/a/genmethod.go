@@ -0,0 +1,5 @@
+package a
+
+func genMethod(t T) {
+	println(t.Sum())
+}
@@ -0,0 +1,6 @@
+package a
+
+func genParam(e Enum) {
+	n := 1
+	println(e, n)
+}
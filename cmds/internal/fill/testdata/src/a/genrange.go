@@ -0,0 +1,7 @@
+package a
+
+func genRange(es []Enum) {
+	for _, e := range es {
+		println(e)
+	}
+}
@@ -0,0 +1,5 @@
+package a
+
+func genShadow(t T) {
+	println(t.B())
+}
@@ -0,0 +1,6 @@
+package a
+
+func genSwitch(f func() Enum) {
+	switch k := f(); k {
+	}
+}
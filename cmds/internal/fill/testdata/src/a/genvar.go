@@ -0,0 +1,8 @@
+package a
+
+import "b"
+
+func genVar() {
+	var s b.Sum = b.P{}
+	println(s)
+}
@@ -0,0 +1,7 @@
+package a
+
+func ifChain(e Enum) {
+	if e == B {
+	} else if e == A || e == C {
+	}
+}
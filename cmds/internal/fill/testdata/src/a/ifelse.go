@@ -0,0 +1,9 @@
+package a
+
+func ifElse(e Enum) int {
+	if e == A {
+		return 1
+	} else {
+		return 0
+	}
+}
@@ -0,0 +1,8 @@
+package a
+
+func merge(e Enum) {
+	switch e {
+	case D:
+	case A:
+	}
+}
@@ -0,0 +1,9 @@
+package a
+
+import "b"
+
+func mergeSum(s b.Sum) {
+	switch s.(type) {
+	case *b.Q:
+	}
+}
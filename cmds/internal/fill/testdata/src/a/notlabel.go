@@ -0,0 +1,12 @@
+package a
+
+func notLabel(e Enum, n int) {
+	switch e {
+	case A:
+		if e == 7 {
+		} else if e == B {
+		}
+		if n == 3 {
+		}
+	}
+}
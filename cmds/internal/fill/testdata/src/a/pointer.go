@@ -0,0 +1,12 @@
+package a
+
+type Open int
+
+func pointer(p *Enum, o Open) {
+	switch *p {
+	}
+	switch p {
+	}
+	switch o {
+	}
+}
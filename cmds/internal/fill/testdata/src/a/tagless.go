@@ -0,0 +1,7 @@
+package a
+
+func tagless(e Enum) {
+	switch {
+	case e == A:
+	}
+}
@@ -0,0 +1,40 @@
+package b
+
+type Enum int
+
+const (
+	X Enum = iota
+	Y
+)
+
+type Sum interface {
+	isSum()
+}
+
+type P struct{}
+
+func (P) isSum() {}
+
+type Q struct{}
+
+func (*Q) isSum() {}
+
+type O struct{}
+
+func (O) isSum() {}
+
+type EnumAlias = Enum
+
+type SumAlias = Sum
+
+type GSum[T any] interface {
+	isGSum()
+}
+
+type GA struct{}
+
+func (GA) isGSum() {}
+
+type GB struct{}
+
+func (GB) isGSum() {}
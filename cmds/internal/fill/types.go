@@ -0,0 +1,201 @@
+package fill
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func typeOf(theSwitch ast.Stmt, ti types.Info) (types.Type, error) {
+	var t types.Type
+
+	switch s := theSwitch.(type) {
+	case *ast.TypeSwitchStmt:
+		switch a := s.Assign.(type) {
+		case *ast.AssignStmt:
+			t = ti.TypeOf(a.Rhs[0].(*ast.TypeAssertExpr).X)
+
+		case *ast.ExprStmt:
+			t = ti.TypeOf(a.X.(*ast.TypeAssertExpr).X)
+
+		default:
+			panic(fmt.Errorf("unexpected %T in TypeSwitchStmt.Assign", s.Assign))
+		}
+
+	case *ast.SwitchStmt:
+		if s.Tag != nil {
+			t = ti.TypeOf(s.Tag)
+			break
+		}
+		x, err := subjectOf(s, ti)
+		if err != nil {
+			return nil, err
+		}
+		t = ti.TypeOf(x)
+
+	case *ast.IfStmt:
+		x, err := subjectOf(s, ti)
+		if err != nil {
+			return nil, err
+		}
+		t = ti.TypeOf(x)
+	}
+
+	if t == nil {
+		return nil, errors.New("could not derive type of switch statement")
+	}
+	return t, nil
+}
+
+//typeNameOf the defined type t.
+//
+//Aliases are resolved and instances of generic types are
+//treated as their generic type.
+func typeNameOf(t types.Type) (*types.TypeName, error) {
+	switch T := types.Unalias(t).(type) {
+	case *types.Named:
+		return T.Origin().Obj(), nil
+	case *types.Pointer:
+		if _, ok := types.Unalias(T.Elem()).(*types.Named); ok {
+			return nil, fmt.Errorf("%s is a pointer to a named type, switch on the value it points to instead", t)
+		}
+	case *types.TypeParam:
+		return nil, fmt.Errorf("%s is a type parameter, not a closed type", t)
+	}
+	return nil, fmt.Errorf("%s is not a named type", t)
+}
+
+//resolveClosed finds the closed type that a switch over t should be filled from.
+//
+//If t is not itself closed but is defined as another type that is,
+//such as
+//	type Local other.Enum
+//then that closed type is returned and conv is t,
+//as the labels of an enum must be converted to t.
+func resolveClosed(t types.Type, prog *loader.Program, lookup Lookup) (ct closed.Type, dpkg *loader.PackageInfo, conv types.Type, err error) {
+	T := t
+	for {
+		nt, err := typeNameOf(T)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		dpkg, err = definingPackage(nt, prog)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		ct, err = getClosed(nt, prog.Fset, dpkg, lookup)
+		if err == nil {
+			if T != t {
+				conv = types.Unalias(t)
+			}
+			return ct, dpkg, conv, nil
+		}
+
+		next := definedAs(nt, dpkg)
+		if next == nil {
+			return nil, nil, nil, err
+		}
+		T = next
+	}
+}
+
+//definedAs returns the named type t was defined as,
+//if any.
+func definedAs(t *types.TypeName, pkg *loader.PackageInfo) types.Type {
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			g, ok := d.(*ast.GenDecl)
+			if !ok || g.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range g.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Assign.IsValid() || pkg.Info.Defs[ts.Name] != t {
+					continue
+				}
+				T := pkg.Info.TypeOf(ts.Type)
+				if _, ok := types.Unalias(T).(*types.Named); ok {
+					return T
+				}
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func definingPackage(t *types.TypeName, prog *loader.Program) (pkg *loader.PackageInfo, err error) {
+	if t.Pkg() == nil {
+		return nil, fmt.Errorf("%s is predeclared, not a closed type", t.Name())
+	}
+	pkg = prog.Package(t.Pkg().Path())
+	if pkg == nil {
+		return nil, fmt.Errorf("could not load package for %s", t)
+	}
+	return pkg, nil
+}
+
+func getClosed(t *types.TypeName, fs *token.FileSet, pkg *loader.PackageInfo, lookup Lookup) (closed.Type, error) {
+	closedTypes, err := lookup.in(fs, pkg)
+	if err := closedutil.Fatal(err); err != nil {
+		return nil, err
+	}
+	ct := closedutil.Find(t, closedTypes)
+	if ct == nil {
+		return nil, fmt.Errorf("%s is not recognized as a closed type: %s", t.Name(), closedutil.WhyNot(fs, pkg.Files, t))
+	}
+	switch ct.(type) {
+	default:
+		return nil, fmt.Errorf("internal error: unrecognized closed type %T", ct)
+	case *closed.Bitset, *closed.OptionalStruct:
+		return nil, fmt.Errorf("%T cannot be used in switch", ct)
+	case *closed.Enum, *closed.Interface, *closed.EmptySum:
+		return ct, nil
+	}
+}
+
+//shrinkUsed removes item i from a TypeAndValue slice.
+func shrinkUsed(used []types.TypeAndValue, i int) []types.TypeAndValue {
+	if len(used) == 0 {
+		return nil
+	}
+	last := len(used) - 1
+	used[last], used[i] = used[i], used[last]
+	return used[:last]
+}
+
+//removeNilCase removes "case nil:" from a TypeAndValue slice and reports whether it had to.
+func removeNilCase(ts []types.TypeAndValue) ([]types.TypeAndValue, bool) {
+	for i, t := range ts {
+		if t.IsNil() {
+			return shrinkUsed(ts, i), true
+		}
+	}
+	return ts, false
+}
+
+//usedBy returns the types used by by.
+func usedBy(b *ast.BlockStmt, m map[ast.Expr]types.TypeAndValue) (acc []types.TypeAndValue, noDefault bool) {
+	noDefault = true
+	for _, c := range b.List {
+		c := c.(*ast.CaseClause)
+		if c.List == nil {
+			noDefault = false
+		}
+		for _, x := range c.List {
+			if tv, ok := m[x]; ok {
+				acc = append(acc, tv)
+			}
+		}
+	}
+	return acc, noDefault
+}
@@ -0,0 +1,62 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+)
+
+//Imports computes a stable set of local aliases
+//for importing into the generated code and a map of import paths
+//to these local aliases.
+func Imports(here string, c closed.Type) (imports []string, import2name map[string]string, err error) {
+	impset, err := closedutil.ImportsOf(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sorted := make([]string, 0, len(impset))
+	for k := range impset {
+		if k == here {
+			continue
+		}
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	out := make(map[string]string, len(sorted))
+	for i, v := range sorted {
+		out[v] = fmt.Sprintf("pkg%d", i)
+		imports = append(imports, fmt.Sprintf("pkg%d %q", i, v))
+	}
+	return imports, out, nil
+}
+
+//MustFunc returns true if c does not allow methods.
+func MustFunc(c closed.Type) bool {
+	switch c.(type) {
+	case *closed.Interface, *closed.EmptySum:
+		return true
+	}
+	return false
+}
+
+//ValidatorName is the default name of the validator of the type name:
+//legal for methods and legalName for funcs.
+func ValidatorName(name string, fn bool) string {
+	if !fn {
+		return "legal"
+	}
+	if !ast.IsExported(name) {
+		r, sz := utf8.DecodeRuneInString(name)
+		name = name[sz:]
+		r = unicode.ToUpper(r)
+		name = string(r) + name
+	}
+	return fmt.Sprintf("legal%s", name)
+}
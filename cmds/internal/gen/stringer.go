@@ -0,0 +1,104 @@
+package gen
+
+import (
+	"fmt"
+	"go/types"
+	"io"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+)
+
+//A Stringer generates a String method for an enum or bitset
+//in the package that defines it.
+type Stringer struct {
+	*Writer
+
+	ToolName string
+
+	//Name of the closed type T.
+	Name string
+	T    closed.Type
+
+	BuildTags []byte
+
+	PackageName string
+}
+
+func (g *Stringer) Generate(w io.Writer) error {
+	g.Writer = &Writer{
+		w: w,
+	}
+
+	var imports []string
+	var body func()
+	switch c := g.T.(type) {
+	case *closed.Enum:
+		imports = []string{`"fmt"`}
+		body = func() { g.enum(c) }
+	case *closed.Bitset:
+		imports = []string{`"fmt"`, `"strings"`}
+		body = func() { g.bitset(c) }
+	default:
+		return fmt.Errorf("cannot generate String method for %T", c)
+	}
+
+	g.printf("// Code generated by %s - DO NOT EDIT.\n\n", g.ToolName)
+	if len(g.BuildTags) > 0 {
+		g.write(g.BuildTags)
+	}
+	g.printf("package %s\n", g.PackageName)
+
+	g.println("import (")
+	for _, imp := range imports {
+		g.println(imp)
+	}
+	g.println(")")
+
+	g.printf("func (v %s) String() string {\n", g.Name)
+	body()
+	g.println("}")
+
+	return g.err
+}
+
+//basic is the name of the underlying type of the labels.
+func basic(L [][]*types.Const) string {
+	return L[0][0].Type().Underlying().(*types.Basic).Name()
+}
+
+//name of the label to use for L.
+func name(L []*types.Const) string {
+	if l := closedutil.FirstExportedLabel(L); l != nil {
+		return l.Name()
+	}
+	return L[0].Name()
+}
+
+func (g *Stringer) enum(c *closed.Enum) {
+	g.println("switch v {")
+	for _, L := range c.Labels {
+		n := name(L)
+		g.printf("case %s: return %q\n", n, n)
+	}
+	g.println("}")
+
+	g.printf(`return fmt.Sprintf("%s(%%v)", %s(v))`, g.Name, basic(c.Labels))
+	g.println()
+}
+
+func (g *Stringer) bitset(c *closed.Bitset) {
+	g.println("if v == 0 { return \"0\" }")
+	g.println("var acc []string")
+	for _, L := range c.Flags {
+		n := name(L)
+		g.printf("if v&%s != 0 { acc = append(acc, %q) }\n", n, n)
+	}
+
+	all := fmt.Sprintf("0x%X", closedutil.AllMask(c))
+	g.printf("if rest := v &^ %s; rest != 0 {\n", all)
+	g.printf(`acc = append(acc, fmt.Sprintf("%s(%%#x)", %s(rest)))`, g.Name, basic(c.Flags))
+	g.println("\n}")
+
+	g.println(`return strings.Join(acc, "|")`)
+}
@@ -1,4 +1,5 @@
-package main
+//Package gen generates code for closed types.
+package gen
 
 import (
 	"fmt"
@@ -9,13 +10,19 @@ import (
 	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
 )
 
-type Generator struct {
+//A Validator generates a method or func to validate
+//that the value of a closed type is legal.
+type Validator struct {
 	*Writer
 
 	ToolName string
 
-	T     *Type
-	tqual string
+	//Name of the closed type T in the package that defines it.
+	Name string
+	//ImportPath of the package that defines T.
+	ImportPath string
+	T          closed.Type
+	tqual      string
 
 	FName string
 	Func  bool
@@ -29,14 +36,14 @@ type Generator struct {
 	Imports        []string
 }
 
-func (g *Generator) qual(imp string) string {
+func (g *Validator) qual(imp string) string {
 	if imp == g.ThisPackageImp {
 		return ""
 	}
 	return fmt.Sprintf("%s.", g.ImportNames[imp])
 }
 
-func (g *Generator) typesQual(p *types.Package) string {
+func (g *Validator) typesQual(p *types.Package) string {
 	imp := p.Path()
 	if imp == g.ThisPackageImp {
 		return ""
@@ -44,20 +51,20 @@ func (g *Generator) typesQual(p *types.Package) string {
 	return g.ImportNames[imp]
 }
 
-func (g *Generator) Generate(w io.Writer) error {
+func (g *Validator) Generate(w io.Writer) error {
 	g.Writer = &Writer{
 		w: w,
 	}
-	g.tqual = g.qual(g.T.Pkg.ImportPath)
+	g.tqual = g.qual(g.ImportPath)
 
 	g.header()
 	g.decl()
 
 	// fill in the body
-	if closedutil.AlwaysValid(g.T.T) {
+	if closedutil.AlwaysValid(g.T) {
 		g.println("return nil")
 	} else {
-		switch c := g.T.T.(type) {
+		switch c := g.T.(type) {
 		case *closed.Interface:
 			g.interfaceSum(c)
 		case *closed.EmptySum:
@@ -78,7 +85,7 @@ func (g *Generator) Generate(w io.Writer) error {
 	return g.err
 }
 
-func (g *Generator) header() {
+func (g *Validator) header() {
 	g.printf("// Code generated by %s - DO NOT EDIT.\n\n", g.ToolName)
 
 	if len(g.BuildTags) > 0 {
@@ -95,32 +102,32 @@ func (g *Generator) header() {
 	g.println(")")
 }
 
-func (g *Generator) decl() {
-	g.printf("//%s checks that v is a legal value of %s.\n", g.FName, g.T.Name)
+func (g *Validator) decl() {
+	g.printf("//%s checks that v is a legal value of %s.\n", g.FName, g.Name)
 
 	g.print("func ")
 	if g.Func {
-		g.printf("%s(v %s%s)", g.FName, g.tqual, g.T.Name)
+		g.printf("%s(v %s%s)", g.FName, g.tqual, g.Name)
 	} else {
-		g.printf("(v %s%s) %s", g.tqual, g.T.Name, g.FName)
+		g.printf("(v %s%s) %s()", g.tqual, g.Name, g.FName)
 	}
 	g.println(" error {")
 }
 
-func (g *Generator) comma(n int, len int) {
+func (g *Validator) comma(n int, len int) {
 	if n != len-1 {
 		g.print(",")
 	}
 }
 
-func (g *Generator) interfaceSum(c *closed.Interface) {
+func (g *Validator) interfaceSum(c *closed.Interface) {
 	g.println("switch v.(type) {")
 
 	g.print("case nil")
 	if c.NonNil {
 		g.println(":")
 		//Note that %T is evaluated now
-		g.printf(`return fmt.Errorf("%T must not be nil")`, g.T.Name)
+		g.printf(`return fmt.Errorf("%T must not be nil")`, g.Name)
 		g.print("\ncase ")
 	} else {
 		g.print(",")
@@ -148,17 +155,17 @@ func (g *Generator) interfaceSum(c *closed.Interface) {
 
 	g.println("}")
 
-	g.printf(`return fmt.Errorf("type %%T is not a legal type of %s", v)`, g.T.Name)
+	g.printf(`return fmt.Errorf("type %%T is not a legal type of %s", v)`, g.Name)
 }
 
-func (g *Generator) emptySum(c *closed.EmptySum) {
+func (g *Validator) emptySum(c *closed.EmptySum) {
 	g.println("switch v.(type) {")
 
 	g.print("case nil")
 	if c.Nil {
 		g.print(",")
 	} else {
-		g.printf(`: return fmt.Errorf("%s must not be nil")`, g.T.Name)
+		g.printf(`: return fmt.Errorf("%s must not be nil")`, g.Name)
 		g.print("\ncase ")
 	}
 
@@ -171,13 +178,13 @@ func (g *Generator) emptySum(c *closed.EmptySum) {
 
 	g.println("}")
 
-	g.printf(`return fmt.Errorf("%%T is not a legal type of %s", v)`, g.T.Name)
+	g.printf(`return fmt.Errorf("%%T is not a legal type of %s", v)`, g.Name)
 }
 
-func (g *Generator) enum(c *closed.Enum) {
+func (g *Validator) enum(c *closed.Enum) {
 	doZ := !c.NonZero && !closedutil.ContainsLabeledZero(c)
 	if doZ {
-		g.printf("var z %s%s", g.tqual, g.T.Name)
+		g.printf("var z %s%s\n", g.tqual, g.Name)
 	}
 
 	g.println("switch v {")
@@ -195,16 +202,16 @@ func (g *Generator) enum(c *closed.Enum) {
 
 	g.println("}")
 
-	g.printf(`return fmt.Errorf("%%v is not a legal value of %s", v)`, g.T.Name)
+	g.printf(`return fmt.Errorf("%%v is not a legal value of %s", v)`, g.Name)
 }
 
-func (g *Generator) bitset(c *closed.Bitset) {
+func (g *Validator) bitset(c *closed.Bitset) {
 	all := fmt.Sprintf("0x%X", closedutil.AllMask(c))
 	g.printf("if v &^ %s == 0 { return nil }\n", all)
-	g.printf(`return fmt.Errorf("%s has illegal bits set %%b", v &^ %s)`, g.T.Name, all)
+	g.printf(`return fmt.Errorf("%s has illegal bits set %%b", v &^ %s)`, g.Name, all)
 }
 
-func (g *Generator) optionalStruct(c *closed.OptionalStruct) {
+func (g *Validator) optionalStruct(c *closed.OptionalStruct) {
 	//create zero value of c.Field
 	g.print("var z ")
 	g.println(types.TypeString(c.Field.Type(), g.typesQual))
@@ -0,0 +1,226 @@
+//Package bitops defines an Analyzer that reports operations on closed bitsets
+//that may set bits outside of their flags.
+package bitops
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedtypes"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/passutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "bitops",
+	Doc: `report bitset operations that may set illegal bits
+
+For a closed bitset, the legal values are those with only the bits of its flags set.
+The operations &, &^, and | and ^ between legal values are always legal.
+This reports
+	* arithmetic: +, -, *, /, %, ++, --, and negation
+	* shifts
+	* complement, ^x, which is suggested be replaced by the mask of all flags &^ x
+	* | and ^ with constants that have bits outside of the flags
+	* constant expressions with bits outside of the flags
+when the result is of a closed bitset type.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, closedtypes.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cts := pass.ResultOf[closedtypes.Analyzer].(*closedtypes.Result)
+	info := pass.TypesInfo
+
+	bitsetOf := func(x ast.Expr) *closed.Bitset {
+		b, _ := cts.Of(info.TypeOf(x)).(*closed.Bitset)
+		if b == nil || closedutil.AlwaysValid(b) {
+			return nil
+		}
+		return b
+	}
+
+	filter := []ast.Node{
+		(*ast.GenDecl)(nil),
+		(*ast.BinaryExpr)(nil),
+		(*ast.UnaryExpr)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.IncDecStmt)(nil),
+	}
+	inspect.Nodes(filter, func(n ast.Node, push bool) bool {
+		if !push {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.GenDecl:
+			//the flags are defined with shifts and ors
+			return n.Tok != token.CONST
+
+		case *ast.BinaryExpr:
+			b := bitsetOf(n)
+			if b == nil {
+				return true
+			}
+			if v := info.Types[n].Value; v != nil {
+				if !passutil.LegalConst(b, v) {
+					pass.Reportf(n.Pos(), "constant %s has bits outside the flags of %s", v, name(b))
+				}
+				//no need to look at the operands
+				return false
+			}
+			check(pass, b, n.Op, n.X, n.Y, n.Pos())
+
+		case *ast.UnaryExpr:
+			b := bitsetOf(n)
+			if b == nil || info.Types[n].Value != nil {
+				return true
+			}
+			switch n.Op {
+			case token.SUB:
+				pass.Reportf(n.Pos(), "negation of %s may set bits outside its flags", name(b))
+			case token.XOR:
+				complement(pass, b, n)
+			}
+
+		case *ast.AssignStmt:
+			op, ok := assignOps[n.Tok]
+			if !ok || len(n.Lhs) != 1 {
+				return true
+			}
+			if b := bitsetOf(n.Lhs[0]); b != nil {
+				check(pass, b, op, n.Lhs[0], n.Rhs[0], n.TokPos)
+			}
+
+		case *ast.IncDecStmt:
+			if b := bitsetOf(n.X); b != nil {
+				pass.Reportf(n.TokPos, "%s on %s may set bits outside its flags", n.Tok, name(b))
+			}
+		}
+		return true
+	})
+
+	return nil, nil
+}
+
+var assignOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN: token.ADD,
+	token.SUB_ASSIGN: token.SUB,
+	token.MUL_ASSIGN: token.MUL,
+	token.QUO_ASSIGN: token.QUO,
+	token.REM_ASSIGN: token.REM,
+	token.SHL_ASSIGN: token.SHL,
+	token.SHR_ASSIGN: token.SHR,
+	token.OR_ASSIGN:  token.OR,
+	token.XOR_ASSIGN: token.XOR,
+}
+
+func name(b *closed.Bitset) string {
+	return b.Types()[0].Name()
+}
+
+//check the binary operation x op y, whose result is of type b.
+func check(pass *analysis.Pass, b *closed.Bitset, op token.Token, x, y ast.Expr, pos token.Pos) {
+	switch op {
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+		pass.Reportf(pos, "arithmetic on %s may set bits outside its flags", name(b))
+
+	case token.SHL, token.SHR:
+		pass.Reportf(pos, "shift of %s may set bits outside its flags", name(b))
+
+	case token.OR, token.XOR:
+		for _, z := range []ast.Expr{x, y} {
+			v := pass.TypesInfo.Types[z].Value
+			if v != nil && !passutil.LegalConst(b, v) {
+				pass.Reportf(z.Pos(), "constant %s has bits outside the flags of %s", v, name(b))
+			}
+		}
+	}
+}
+
+//complement reports ^x and suggests mask &^ x.
+func complement(pass *analysis.Pass, b *closed.Bitset, n *ast.UnaryExpr) {
+	mask, ok := maskExpr(pass, b, n.Pos())
+	if !ok {
+		pass.Reportf(n.Pos(), "^ of %s sets bits outside its flags: use the mask of all flags &^ x", name(b))
+		return
+	}
+
+	var x bytes.Buffer
+	if err := format.Node(&x, pass.Fset, n.X); err != nil {
+		return
+	}
+	repl := fmt.Sprintf("(%s) &^ %s", mask, x.String())
+	pass.Report(analysis.Diagnostic{
+		Pos:     n.Pos(),
+		End:     n.End(),
+		Message: fmt.Sprintf("^ of %s sets bits outside its flags: use %s", name(b), repl),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message: fmt.Sprintf("Replace with %s", repl),
+			TextEdits: []analysis.TextEdit{{
+				Pos:     n.Pos(),
+				End:     n.End(),
+				NewText: []byte(repl),
+			}},
+		}},
+	})
+}
+
+//maskExpr returns an expression that is the or of all flags of b as written at pos.
+func maskExpr(pass *analysis.Pass, b *closed.Bitset, pos token.Pos) (string, bool) {
+	qual := ""
+	if pkg := b.Types()[0].Pkg(); pkg != pass.Pkg {
+		nm, ok := importName(pass, pkg, pos)
+		if !ok {
+			return "", false
+		}
+		qual = nm + "."
+	}
+
+	var buf bytes.Buffer
+	for i, f := range b.Flags {
+		L := f[0]
+		if qual != "" {
+			if L = closedutil.FirstExportedLabel(f); L == nil {
+				return "", false
+			}
+		}
+		if i > 0 {
+			buf.WriteString(" | ")
+		}
+		buf.WriteString(qual)
+		buf.WriteString(L.Name())
+	}
+	return buf.String(), true
+}
+
+//importName of pkg in the file containing pos.
+func importName(pass *analysis.Pass, pkg *types.Package, pos token.Pos) (string, bool) {
+	for _, f := range pass.Files {
+		if f.FileStart > pos || pos > f.FileEnd {
+			continue
+		}
+		for _, imp := range f.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || path != pkg.Path() {
+				continue
+			}
+			if imp.Name == nil {
+				return pkg.Name(), true
+			}
+			if nm := imp.Name.Name; nm != "_" && nm != "." {
+				return nm, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,12 @@
+package bitops_test
+
+import (
+	"testing"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/bitops"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), bitops.Analyzer, "a")
+}
@@ -0,0 +1,40 @@
+package a
+
+import "flags"
+
+func ops(f, g flags.Flags, n uint) {
+	_ = f | g
+	_ = f & g
+	_ = f &^ g
+	_ = f ^ g
+	_ = f | flags.Read
+	_ = flags.Read | flags.Exec
+	_ = f | 8             // want `constant 8 has bits outside the flags of Flags`
+	_ = flags.Read | 0x80 // want `constant 129 has bits outside the flags of Flags`
+	_ = f + g             // want `arithmetic on Flags may set bits outside its flags`
+	_ = f << n            // want `shift of Flags may set bits outside its flags`
+	_ = -f                // want `negation of Flags may set bits outside its flags`
+	_ = ^f                // want `\^ of Flags sets bits outside its flags: use \(flags.Read \| flags.Write \| flags.Exec\) &\^ f`
+	f |= 0x10             // want `constant 16 has bits outside the flags of Flags`
+	f <<= 1               // want `shift of Flags may set bits outside its flags`
+	f++                   // want `\+\+ on Flags may set bits outside its flags`
+	f &^= g
+}
+
+type Local uint
+
+const (
+	A Local = 1 << iota
+	B
+	C
+	D
+	AB = A | B
+)
+
+func local(l Local) Local {
+	return ^l // want `use \(A \| B \| C \| D\) &\^ l`
+}
+
+func hidden(h flags.Hidden) {
+	_ = ^h // want `\^ of Hidden sets bits outside its flags: use the mask of all flags &\^ x`
+}
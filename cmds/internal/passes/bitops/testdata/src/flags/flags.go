@@ -0,0 +1,18 @@
+package flags
+
+type Flags uint8
+
+const (
+	Read Flags = 1 << iota
+	Write
+	Exec
+)
+
+//Hidden is a bitset whose flags cannot be named outside this package.
+type Hidden uint8
+
+const (
+	hidden0 Hidden = 1 << iota
+	hidden1
+	hidden2
+)
@@ -0,0 +1,176 @@
+//Package closedconv defines an Analyzer that reports conversions
+//that may create illegal values of closed enums and bitsets.
+package closedconv
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedtypes"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/passutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "closedconv",
+	Doc: `report unchecked conversions into closed enums and bitsets
+
+A conversion such as E(n) with a non-constant operand may create
+a value of the closed type E that is not legal.
+Such conversions are reported unless
+	* the result is assigned to a variable that is validated
+	  by the following statements, as with
+		e := E(n)
+		if err := e.legal(); err != nil {
+	  where a validation is any method on, or func of, the variable
+	  that returns only an error, such as those generated by clvalid
+	* the result is only compared against other values,
+	  as in a switch over E(n) or E(n) == A.
+
+Conversions of constants are reported if the constant
+is not a label of the enum or sets bits outside the flags of the bitset.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, closedtypes.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cts := pass.ResultOf[closedtypes.Analyzer].(*closedtypes.Result)
+
+	filter := []ast.Node{(*ast.CallExpr)(nil)}
+	inspect.WithStack(filter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		call := n.(*ast.CallExpr)
+		if !passutil.IsConversion(pass.TypesInfo, call) {
+			return true
+		}
+
+		T := pass.TypesInfo.TypeOf(call)
+		ct := cts.Of(T)
+		switch ct.(type) {
+		case *closed.Enum, *closed.Bitset:
+		default:
+			return true
+		}
+		name := ct.Types()[0].Name()
+
+		if v := pass.TypesInfo.Types[call].Value; v != nil {
+			if !passutil.LegalConst(ct, v) {
+				what := "a label of"
+				if _, ok := ct.(*closed.Bitset); ok {
+					what = "within the flags of"
+				}
+				pass.Reportf(call.Pos(), "conversion of constant %s is not %s %s", v, what, name)
+			}
+			return true
+		}
+
+		//conversions from the type itself cannot create new values
+		if types.Identical(pass.TypesInfo.TypeOf(call.Args[0]), T) {
+			return true
+		}
+
+		if compared(call, stack) || validated(pass.TypesInfo, call, stack) {
+			return true
+		}
+
+		pass.Reportf(call.Pos(), "unchecked conversion to %s may create an illegal value", name)
+		return true
+	})
+
+	return nil, nil
+}
+
+//compared reports whether the result of the conversion is only compared against.
+func compared(call *ast.CallExpr, stack []ast.Node) bool {
+	parent := stack[len(stack)-2]
+	switch p := parent.(type) {
+	case *ast.SwitchStmt:
+		return p.Tag == call
+	case *ast.BinaryExpr:
+		return p.Op == token.EQL || p.Op == token.NEQ
+	}
+	return false
+}
+
+//validated reports whether call is assigned to a variable
+//that is validated in the statements that follow.
+func validated(info *types.Info, call *ast.CallExpr, stack []ast.Node) bool {
+	v := assignedTo(info, call, stack[len(stack)-2])
+	if v == nil {
+		return false
+	}
+
+	//the assignment may be the init of an if or switch that validates it
+	stmt := stack[len(stack)-2]
+	if len(stack) > 2 {
+		switch p := stack[len(stack)-3].(type) {
+		case *ast.IfStmt:
+			if p.Init == stmt && validates(info, p.Cond, v) {
+				return true
+			}
+		case *ast.SwitchStmt:
+			if p.Init == stmt && p.Tag != nil && validates(info, p.Tag, v) {
+				return true
+			}
+		}
+	}
+
+	list, at, ok := passutil.StmtList(stack[:len(stack)-1])
+	if !ok {
+		return false
+	}
+	for _, s := range list[at+1:] {
+		if validates(info, s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+//assignedTo returns the variable the result of call is assigned to, if any.
+func assignedTo(info *types.Info, call *ast.CallExpr, parent ast.Node) types.Object {
+	var lhs []ast.Expr
+	var rhs []ast.Expr
+	switch p := parent.(type) {
+	case *ast.AssignStmt:
+		lhs, rhs = p.Lhs, p.Rhs
+	case *ast.ValueSpec:
+		for _, nm := range p.Names {
+			lhs = append(lhs, nm)
+		}
+		rhs = p.Values
+	default:
+		return nil
+	}
+	if len(lhs) != len(rhs) {
+		return nil
+	}
+	for i, x := range rhs {
+		if x == call {
+			return passutil.ObjectOf(info, lhs[i])
+		}
+	}
+	return nil
+}
+
+//validates reports whether n unconditionally contains a validation of v.
+func validates(info *types.Info, n ast.Node, v types.Object) bool {
+	found := false
+	passutil.Unconditionally(n, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		if call, ok := n.(*ast.CallExpr); ok && passutil.IsValidation(info, call, v) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
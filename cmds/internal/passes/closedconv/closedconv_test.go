@@ -0,0 +1,12 @@
+package closedconv_test
+
+import (
+	"testing"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedconv"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), closedconv.Analyzer, "a")
+}
@@ -0,0 +1,64 @@
+package a
+
+import "e"
+
+type Local string
+
+const (
+	L1 Local = "one"
+	L2 Local = "two"
+)
+
+func constants() {
+	_ = e.Enum(2)
+	_ = e.Enum(0)
+	_ = e.Enum(7) // want `conversion of constant 7 is not a label of Enum`
+	_ = e.Bits(5)
+	_ = e.Bits(16) // want `conversion of constant 16 is not within the flags of Bits`
+	_ = Local("one")
+	_ = Local("three") // want `conversion of constant "three" is not a label of Local`
+}
+
+func unchecked(n int, s string) e.Enum {
+	_ = Local(s)   // want `unchecked conversion to Local may create an illegal value`
+	x := e.Bits(n) // want `unchecked conversion to Bits may create an illegal value`
+	_ = x
+	return e.Enum(n) // want `unchecked conversion to Enum may create an illegal value`
+}
+
+func validated(n int) (e.Enum, error) {
+	v := e.Enum(n)
+	if err := v.Legal(); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func validatedInit(n int) {
+	if v := e.Enum(n); v.Legal() != nil {
+		return
+	}
+}
+
+func validatedFunc(n int) {
+	var v = e.Enum(n)
+	check(v)
+}
+
+func check(e.Enum) error { return nil }
+
+func conditional(n int, b bool) {
+	v := e.Enum(n) // want `unchecked conversion to Enum may create an illegal value`
+	if b {
+		_ = v.Legal()
+	}
+}
+
+func compared(n int) {
+	switch e.Enum(n) {
+	case e.A:
+	}
+	if e.Enum(n) == e.B {
+	}
+	_ = e.Enum(e.A)
+}
@@ -0,0 +1,21 @@
+package e
+
+type Enum int
+
+const (
+	A Enum = iota + 1
+	B
+	C
+)
+
+func (v Enum) Legal() error {
+	return nil
+}
+
+type Bits uint8
+
+const (
+	X Bits = 1 << iota
+	Y
+	Z
+)
@@ -0,0 +1,132 @@
+//Package closedtypes defines an Analyzer that finds the closed types
+//of a package and its dependencies for use by other analyzers.
+package closedtypes
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+	"golang.org/x/tools/go/analysis"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "closedtypes",
+	Doc: `find the closed types of a package
+
+The closedtypes analyzer exports a fact for each closed type in a package
+and returns a *Result that maps types to closed types,
+including those defined in dependencies.`,
+	Run:        run,
+	ResultType: reflect.TypeOf((*Result)(nil)),
+	FactTypes:  []analysis.Fact{(*Fact)(nil)},
+}
+
+//Fact records that a type is closed.
+type Fact struct {
+	closed.Portable
+}
+
+func (*Fact) AFact() {}
+
+func (f *Fact) String() string {
+	return fmt.Sprintf("closed %s %s", f.Kind, strings.Join(f.Types, " = "))
+}
+
+//Result of the closedtypes Analyzer.
+//
+//A Result is shared by every pass that requires closedtypes
+//and is safe for concurrent use.
+type Result struct {
+	pkg   *types.Package
+	local []closed.Type
+
+	mu sync.Mutex
+	//of maps the names of each closed type, local or resolved, to that type
+	of map[*types.TypeName]closed.Type
+	//facts yet to be resolved
+	facts map[*types.TypeName]*Fact
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	local, err := closed.InPackage(pass.Fset, pass.Files, pass.Pkg)
+	//the types that could not be understood are not closed as far as the passes know
+	if err := closedutil.Fatal(err); err != nil {
+		return nil, err
+	}
+
+	r := &Result{
+		pkg:   pass.Pkg,
+		local: local,
+		of:    map[*types.TypeName]closed.Type{},
+		facts: map[*types.TypeName]*Fact{},
+	}
+
+	for _, t := range local {
+		for _, nm := range t.Types() {
+			r.of[nm] = t
+		}
+		p, err := closed.ToPortable(t)
+		if err != nil {
+			//a type we cannot describe is simply not visible to importers
+			continue
+		}
+		pass.ExportObjectFact(t.Types()[0], &Fact{Portable: *p})
+	}
+
+	for _, f := range pass.AllObjectFacts() {
+		t, ok := f.Object.(*types.TypeName)
+		if !ok || t.Pkg() == pass.Pkg {
+			continue
+		}
+		r.facts[t] = f.Fact.(*Fact)
+	}
+
+	return r, nil
+}
+
+//Local returns the closed types defined in the package being analyzed.
+func (r *Result) Local() []closed.Type {
+	return r.local
+}
+
+//Of returns the closed type of t, or nil if t is not closed.
+//
+//Aliases are followed and instances of generic types
+//are treated as their generic type.
+func (r *Result) Of(t types.Type) closed.Type {
+	n, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return nil
+	}
+	o := n.Origin().Obj()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ct, ok := r.of[o]; ok {
+		return ct
+	}
+
+	f, ok := r.facts[o]
+	if !ok {
+		return nil
+	}
+	delete(r.facts, o)
+
+	ct, err := f.Resolve(o.Pkg())
+	if err != nil {
+		//the fact was computed on a different view of the package
+		//that we cannot reconcile so treat the type as open
+		r.of[o] = nil
+		return nil
+	}
+	for _, nm := range ct.Types() {
+		r.of[nm] = ct
+	}
+	return ct
+}
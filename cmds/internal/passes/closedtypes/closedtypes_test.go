@@ -0,0 +1,55 @@
+package closedtypes_test
+
+import (
+	"go/types"
+	"sync"
+	"testing"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedtypes"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	results := analysistest.Run(t, analysistest.TestData(), closedtypes.Analyzer, "a")
+	r := results[0].Result.(*closedtypes.Result)
+
+	var dep *types.Package
+	for _, imp := range results[0].Pass.Pkg.Imports() {
+		if imp.Path() == "dep" {
+			dep = imp
+		}
+	}
+	if dep == nil {
+		t.Fatal("dep not imported")
+	}
+
+	//passes share the Result, so it must be safe to resolve facts concurrently
+	var wg sync.WaitGroup
+	got := make([][]closed.Type, 8)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, nm := range []string{"Enum", "Sum", "X"} {
+				got[i] = append(got[i], r.Of(dep.Scope().Lookup(nm).Type()))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, cts := range got {
+		if _, ok := cts[0].(*closed.Enum); !ok {
+			t.Errorf("%d: got %T for Enum, want *closed.Enum", i, cts[0])
+		}
+		if _, ok := cts[1].(*closed.Interface); !ok {
+			t.Errorf("%d: got %T for Sum, want *closed.Interface", i, cts[1])
+		}
+		if cts[2] != nil {
+			t.Errorf("%d: got %T for X, want nil", i, cts[2])
+		}
+		if cts[0] != got[0][0] || cts[1] != got[0][1] {
+			t.Errorf("%d: resolved a fact more than once", i)
+		}
+	}
+}
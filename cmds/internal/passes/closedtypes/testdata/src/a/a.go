@@ -0,0 +1,8 @@
+package a
+
+import "dep"
+
+var (
+	_ dep.Enum
+	_ dep.Sum
+)
@@ -0,0 +1,21 @@
+package dep
+
+type Enum int
+
+const (
+	A Enum = iota
+	B
+	C
+)
+
+type Sum interface {
+	isSum()
+}
+
+type X struct{}
+
+func (X) isSum() {}
+
+type Y struct{}
+
+func (Y) isSum() {}
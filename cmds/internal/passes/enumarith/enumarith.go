@@ -0,0 +1,199 @@
+//Package enumarith defines an Analyzer that reports arithmetic
+//and ordering on closed enums that do not define them.
+package enumarith
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedtypes"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/passutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "enumarith",
+	Doc: `report arithmetic and ordering on closed enums
+
+The labels of an enum are names, so arithmetic on them,
+such as k+1, usually creates values that are not labels
+and comparing their order, such as k < maxKind,
+on an enum with gaps between its labels
+depends on how the labels happen to be numbered.
+
+Ordering comparisons are allowed on enums whose labels are contiguous.
+An enum with gaps may declare that the order of its labels is meaningful,
+regardless of how they are numbered, with the directive
+	//closed:ordered
+in its doc comment, and ordering comparisons are then allowed on it as well.
+If the labels of an ordered enum are also contiguous,
+stepping from one label to the next with ++, --, or +/- a constant is allowed,
+as in
+	for k := A; k <= C; k++ {
+
+All other arithmetic on enums is reported,
+as are constant expressions that are not labels.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, closedtypes.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cts := pass.ResultOf[closedtypes.Analyzer].(*closedtypes.Result)
+	info := pass.TypesInfo
+
+	enumOf := func(x ast.Expr) *closed.Enum {
+		e, _ := cts.Of(info.TypeOf(x)).(*closed.Enum)
+		return e
+	}
+
+	filter := []ast.Node{
+		(*ast.GenDecl)(nil),
+		(*ast.BinaryExpr)(nil),
+		(*ast.UnaryExpr)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.IncDecStmt)(nil),
+	}
+	inspect.Nodes(filter, func(n ast.Node, push bool) bool {
+		if !push {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.GenDecl:
+			//the labels may be defined with arithmetic
+			return n.Tok != token.CONST
+
+		case *ast.BinaryExpr:
+			if isOrdering(n.Op) {
+				e := enumOf(n.X)
+				if e == nil {
+					return true
+				}
+				if !e.Ordered && !contiguous(e) {
+					pass.Reportf(n.OpPos, "ordering comparison of %s, which has gaps between its labels", name(e))
+				}
+				return true
+			}
+
+			e := enumOf(n)
+			if e == nil {
+				return true
+			}
+			if v := info.Types[n].Value; v != nil {
+				if !passutil.LegalConst(e, v) {
+					pass.Reportf(n.Pos(), "constant %s is not a label of %s", v, name(e))
+				}
+				return false
+			}
+			if !steps(info, e, n.Op, n.Y) {
+				pass.Reportf(n.OpPos, "arithmetic on %s%s", name(e), why(e))
+			}
+
+		case *ast.UnaryExpr:
+			if n.Op != token.SUB && n.Op != token.XOR {
+				return true
+			}
+			e := enumOf(n)
+			if e == nil || info.Types[n].Value != nil {
+				return true
+			}
+			pass.Reportf(n.OpPos, "arithmetic on %s%s", name(e), why(e))
+
+		case *ast.AssignStmt:
+			op, ok := assignOps[n.Tok]
+			if !ok || len(n.Lhs) != 1 {
+				return true
+			}
+			e := enumOf(n.Lhs[0])
+			if e != nil && !steps(info, e, op, n.Rhs[0]) {
+				pass.Reportf(n.TokPos, "arithmetic on %s%s", name(e), why(e))
+			}
+
+		case *ast.IncDecStmt:
+			e := enumOf(n.X)
+			if e != nil && !(e.Ordered && contiguous(e)) {
+				pass.Reportf(n.TokPos, "%s on %s%s", n.Tok, name(e), why(e))
+			}
+		}
+		return true
+	})
+
+	return nil, nil
+}
+
+var assignOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN:     token.ADD,
+	token.SUB_ASSIGN:     token.SUB,
+	token.MUL_ASSIGN:     token.MUL,
+	token.QUO_ASSIGN:     token.QUO,
+	token.REM_ASSIGN:     token.REM,
+	token.AND_ASSIGN:     token.AND,
+	token.OR_ASSIGN:      token.OR,
+	token.XOR_ASSIGN:     token.XOR,
+	token.SHL_ASSIGN:     token.SHL,
+	token.SHR_ASSIGN:     token.SHR,
+	token.AND_NOT_ASSIGN: token.AND_NOT,
+}
+
+func isOrdering(op token.Token) bool {
+	switch op {
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return true
+	}
+	return false
+}
+
+func name(e *closed.Enum) string {
+	return e.Types()[0].Name()
+}
+
+//why arithmetic is not allowed on e.
+func why(e *closed.Enum) string {
+	switch {
+	case !e.Ordered:
+		return ", which is not ordered"
+	case !contiguous(e):
+		return ", which has gaps between its labels"
+	}
+	return ""
+}
+
+//steps reports whether x op y steps between the labels of e.
+func steps(info *types.Info, e *closed.Enum, op token.Token, y ast.Expr) bool {
+	if op != token.ADD && op != token.SUB {
+		return false
+	}
+	if !e.Ordered || !contiguous(e) {
+		return false
+	}
+	return info.Types[y].Value != nil
+}
+
+//contiguous reports whether the labels of an integral enum have no gaps.
+func contiguous(e *closed.Enum) bool {
+	vs := make([]constant.Value, 0, len(e.Labels))
+	for _, L := range e.Labels {
+		v := L[0].Val()
+		if v.Kind() != constant.Int {
+			return false
+		}
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return constant.Compare(vs[i], token.LSS, vs[j])
+	})
+	one := constant.MakeInt64(1)
+	for i := 1; i < len(vs); i++ {
+		next := constant.BinaryOp(vs[i-1], token.ADD, one)
+		if constant.Compare(next, token.NEQ, vs[i]) {
+			return false
+		}
+	}
+	return true
+}
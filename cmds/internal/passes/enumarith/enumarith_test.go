@@ -0,0 +1,12 @@
+package enumarith_test
+
+import (
+	"testing"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/enumarith"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), enumarith.Analyzer, "a")
+}
@@ -0,0 +1,69 @@
+package a
+
+type Kind int
+
+const (
+	K1 Kind = iota
+	K2
+	K3
+)
+
+// Level of severity.
+//
+//closed:ordered
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+)
+
+//closed:ordered
+type Gappy int
+
+const (
+	G1 Gappy = 1
+	G2 Gappy = 2
+	G5 Gappy = 5
+)
+
+func kinds(k, j Kind) {
+	_ = k + 1 // want `arithmetic on Kind, which is not ordered`
+	_ = k < K3
+	_ = k == j
+	_ = K1 + 1
+	_ = K3 + 1 // want `constant 3 is not a label of Kind`
+	k++        // want `\+\+ on Kind, which is not ordered`
+	k += j     // want `arithmetic on Kind, which is not ordered`
+	_ = -k     // want `arithmetic on Kind, which is not ordered`
+}
+
+func levels(l Level) {
+	_ = l < Warn
+	_ = l + 1
+	_ = l * 2 // want `arithmetic on Level$`
+	for x := Debug; x <= Warn; x++ {
+	}
+}
+
+type Sparse int
+
+const (
+	S1 Sparse = 1
+	S2 Sparse = 2
+	S5 Sparse = 5
+)
+
+func sparse(s Sparse) {
+	_ = s < S5 // want `ordering comparison of Sparse, which has gaps between its labels`
+	_ = s == S5
+	_ = s + 1 // want `arithmetic on Sparse, which is not ordered`
+}
+
+func gappy(g Gappy) {
+	_ = g < G5
+	_ = g + 1                   // want `arithmetic on Gappy, which has gaps between its labels`
+	for x := G1; x <= G5; x++ { // want `\+\+ on Gappy, which has gaps between its labels`
+	}
+}
@@ -0,0 +1,382 @@
+//Package exhaustive defines an Analyzer that reports switches over closed types
+//that do not handle every value.
+package exhaustive
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedtypes"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/ssa"
+)
+
+const doc = `report switches over closed types that are missing cases
+
+A switch without a default case over a closed enum must have a case
+for each label, and a type switch without a default case over a closed
+interface or empty sum must have a case for each member.
+The zero value of an enum and the nil interface are not required.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "exhaustive",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, closedtypes.Analyzer},
+	Run:      run,
+}
+
+//SSAAnalyzer is Analyzer with the cases required computed from the SSA form.
+//Building the SSA form of every package is costly, so it is a separate Analyzer.
+var SSAAnalyzer = &analysis.Analyzer{
+	Name: "exhaustivessa",
+	Doc: doc + `
+
+The values that can reach each switch are computed from the
+checks that dominate it, so in
+	if k == A {
+		return
+	}
+	switch k {
+only the labels other than A are required,
+and a case for A is reported as unreachable.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, closedtypes.Analyzer, buildssa.Analyzer},
+	Run:      run,
+}
+
+//domain is the set of values of a closed type a switch must handle.
+type domain struct {
+	name   string
+	labels []constant.Value //of an enum
+	types  []types.Type     //members of a sum
+	names  []string
+}
+
+func (d *domain) len() int {
+	return len(d.names)
+}
+
+//matchConst returns the indices of the values of d that are the constant v.
+func (d *domain) matchConst(v constant.Value) []int {
+	var acc []int
+	for i, L := range d.labels {
+		if constant.Compare(L, token.EQL, v) {
+			acc = append(acc, i)
+		}
+	}
+	return acc
+}
+
+//matchType returns the indices of the values of d that are of type T.
+func (d *domain) matchType(T types.Type) []int {
+	var acc []int
+	iface, _ := T.Underlying().(*types.Interface)
+	for i, m := range d.types {
+		if types.Identical(m, T) || (iface != nil && types.Implements(m, iface)) {
+			acc = append(acc, i)
+		}
+	}
+	return acc
+}
+
+func domainOf(t closed.Type, pkg *types.Package) *domain {
+	if t == nil {
+		return nil
+	}
+	//labels and members are named as they would be written in pkg
+	qual := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+	d := &domain{name: t.Types()[0].Name()}
+	switch t := t.(type) {
+	case *closed.Enum:
+		for _, L := range t.Labels {
+			nm := L[0].Name()
+			if q := qual(L[0].Pkg()); q != "" {
+				nm = q + "." + nm
+			}
+			d.labels = append(d.labels, L[0].Val())
+			d.names = append(d.names, nm)
+		}
+	case *closed.Interface:
+		for _, m := range t.Members {
+			d.types = append(d.types, m.Type)
+		}
+	case *closed.EmptySum:
+		d.types = t.Members
+	default:
+		return nil
+	}
+	for _, T := range d.types {
+		d.names = append(d.names, types.TypeString(T, qual))
+	}
+	return d
+}
+
+//clause is a case of a switch and the values of the domain it handles.
+type clause struct {
+	x ast.Expr
+	//pos of the comparison or type test of x in the SSA form.
+	//Every type test in a clause of a type switch is at the clause.
+	pos  token.Pos
+	vals []int
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cts := pass.ResultOf[closedtypes.Analyzer].(*closedtypes.Result)
+	info := pass.TypesInfo
+
+	//only SSAAnalyzer requires buildssa
+	var at map[token.Pos][]ssa.Instruction
+	ssaRes, useSSA := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	if useSSA {
+		at = instructions(ssaRes)
+	}
+
+	filter := []ast.Node{
+		(*ast.SwitchStmt)(nil),
+		(*ast.TypeSwitchStmt)(nil),
+	}
+	inspect.Preorder(filter, func(n ast.Node) {
+		var (
+			d         *domain
+			clauses   []clause
+			hasDflt   bool
+			reachable []bool
+		)
+		switch n := n.(type) {
+		case *ast.SwitchStmt:
+			if n.Tag == nil {
+				return
+			}
+			e, ok := cts.Of(info.TypeOf(n.Tag)).(*closed.Enum)
+			if !ok {
+				return
+			}
+			d = domainOf(e, pass.Pkg)
+			for _, s := range n.Body.List {
+				cc := s.(*ast.CaseClause)
+				if cc.List == nil {
+					hasDflt = true
+				}
+				for _, x := range cc.List {
+					v := info.Types[x].Value
+					if v == nil {
+						//cannot say what is handled
+						return
+					}
+					clauses = append(clauses, clause{x, x.Pos(), d.matchConst(v)})
+				}
+			}
+			if useSSA {
+				reachable = reach(at, d, clauses)
+			}
+
+		case *ast.TypeSwitchStmt:
+			var x ast.Expr
+			switch a := n.Assign.(type) {
+			case *ast.AssignStmt:
+				x = a.Rhs[0].(*ast.TypeAssertExpr).X
+			case *ast.ExprStmt:
+				x = a.X.(*ast.TypeAssertExpr).X
+			}
+			d = domainOf(cts.Of(info.TypeOf(x)), pass.Pkg)
+			if d == nil {
+				return
+			}
+			for _, s := range n.Body.List {
+				cc := s.(*ast.CaseClause)
+				if cc.List == nil {
+					hasDflt = true
+				}
+				for _, typ := range cc.List {
+					T := info.TypeOf(typ)
+					if T == nil || types.Identical(T, types.Typ[types.UntypedNil]) {
+						continue
+					}
+					clauses = append(clauses, clause{typ, cc.Case, d.matchType(T)})
+				}
+			}
+			if useSSA {
+				reachable = reach(at, d, clauses)
+			}
+
+		default:
+			return
+		}
+		if d == nil || d.len() == 0 {
+			return
+		}
+
+		handled := make([]bool, d.len())
+		for _, c := range clauses {
+			dead := reachable != nil && len(c.vals) > 0
+			for _, i := range c.vals {
+				handled[i] = true
+				if reachable == nil || reachable[i] {
+					dead = false
+				}
+			}
+			if dead {
+				pass.Reportf(c.x.Pos(), "case %s of %s is unreachable: it is ruled out by an earlier check", types.ExprString(c.x), d.name)
+			}
+		}
+
+		if hasDflt {
+			return
+		}
+		var missing []string
+		for i, nm := range d.names {
+			if !handled[i] && (reachable == nil || reachable[i]) {
+				missing = append(missing, nm)
+			}
+		}
+		if len(missing) > 0 {
+			pass.Reportf(n.Pos(), "switch over %s is missing cases for %s", d.name, strings.Join(missing, ", "))
+		}
+	})
+
+	return nil, nil
+}
+
+//instructions maps the positions of the comparisons and type tests
+//that switches compile to, to their instructions.
+//
+//The type tests of a clause of a type switch all have the position of the clause.
+func instructions(s *buildssa.SSA) map[token.Pos][]ssa.Instruction {
+	at := map[token.Pos][]ssa.Instruction{}
+	for _, fn := range s.SrcFuncs {
+		for _, b := range fn.Blocks {
+			for _, in := range b.Instrs {
+				switch in := in.(type) {
+				case *ssa.BinOp:
+					if in.Op == token.EQL {
+						at[in.Pos()] = append(at[in.Pos()], in)
+					}
+				case *ssa.TypeAssert:
+					if in.CommaOk {
+						at[in.Pos()] = append(at[in.Pos()], in)
+					}
+				}
+			}
+		}
+	}
+	return at
+}
+
+//reach returns which values of d can reach the switch with clauses,
+//or nil if that cannot be determined.
+//
+//The switch is found as the comparison or type test of its clauses
+//that dominates the rest,
+//and the values are those not excluded by the conditions of the
+//blocks that dominate it, other than the blocks of the switch itself.
+func reach(at map[token.Pos][]ssa.Instruction, d *domain, clauses []clause) []bool {
+	var first ssa.Instruction
+	own := map[*ssa.BasicBlock]bool{}
+	seen := map[token.Pos]bool{}
+	for _, c := range clauses {
+		if seen[c.pos] {
+			continue
+		}
+		seen[c.pos] = true
+		for _, in := range at[c.pos] {
+			own[in.Block()] = true
+			if first == nil || in.Block().Dominates(first.Block()) {
+				first = in
+			}
+		}
+	}
+
+	var v ssa.Value
+	switch in := first.(type) {
+	case *ssa.BinOp:
+		v = in.X
+	case *ssa.TypeAssert:
+		v = in.X
+	default:
+		return nil
+	}
+	b := first.Block()
+
+	possible := make([]bool, d.len())
+	for i := range possible {
+		possible[i] = true
+	}
+	for dom := b.Idom(); dom != nil; dom = dom.Idom() {
+		iff, ok := dom.Instrs[len(dom.Instrs)-1].(*ssa.If)
+		if !ok || own[dom] {
+			continue
+		}
+		var truth bool
+		switch t, f := dom.Succs[0], dom.Succs[1]; {
+		case len(t.Preds) == 1 && t.Dominates(b):
+			truth = true
+		case len(f.Preds) == 1 && f.Dominates(b):
+			truth = false
+		default:
+			continue
+		}
+		restrict(possible, d, v, iff.Cond, truth)
+	}
+	return possible
+}
+
+//restrict possible to the values of d for which cond on v has the value truth.
+func restrict(possible []bool, d *domain, v, cond ssa.Value, truth bool) {
+	var matches []int
+	switch c := cond.(type) {
+	case *ssa.UnOp:
+		if c.Op == token.NOT {
+			restrict(possible, d, v, c.X, !truth)
+		}
+		return
+
+	case *ssa.BinOp:
+		if c.Op != token.EQL && c.Op != token.NEQ {
+			return
+		}
+		x, y := c.X, c.Y
+		if y == v {
+			x, y = y, x
+		}
+		k, ok := y.(*ssa.Const)
+		if x != v || !ok || k.Value == nil {
+			return
+		}
+		matches = d.matchConst(k.Value)
+		if c.Op == token.NEQ {
+			truth = !truth
+		}
+
+	case *ssa.Extract:
+		ta, ok := c.Tuple.(*ssa.TypeAssert)
+		if !ok || c.Index != 1 || ta.X != v {
+			return
+		}
+		matches = d.matchType(ta.AssertedType)
+
+	default:
+		return
+	}
+
+	is := make([]bool, len(possible))
+	for _, i := range matches {
+		is[i] = true
+	}
+	for i := range possible {
+		if is[i] != truth {
+			possible[i] = false
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package exhaustive_test
+
+import (
+	"testing"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/exhaustive"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), exhaustive.Analyzer, "a")
+}
+
+func TestSSA(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), exhaustive.SSAAnalyzer, "s")
+}
@@ -0,0 +1,60 @@
+package a
+
+import "dep"
+
+type E int
+
+const (
+	A E = iota
+	B
+	C
+)
+
+type I interface {
+	i()
+}
+
+type X struct{}
+
+func (X) i() {}
+
+type Y struct{}
+
+func (*Y) i() {}
+
+func enums(e E) {
+	switch e { // want `switch over E is missing cases for C`
+	case A, B:
+	}
+
+	switch e {
+	case A:
+	default:
+	}
+
+	switch e {
+	case A, B, C:
+	}
+}
+
+func sums(i I) {
+	switch i.(type) { // want `switch over I is missing cases for \*Y`
+	case X:
+	case nil:
+	}
+
+	switch v := i.(type) {
+	case X, *Y:
+		_ = v
+	}
+}
+
+func qualified(e dep.E, i dep.I) {
+	switch e { // want `switch over E is missing cases for dep.C`
+	case dep.A, dep.B:
+	}
+
+	switch i.(type) { // want `switch over I is missing cases for dep.Z`
+	case dep.X, dep.Y:
+	}
+}
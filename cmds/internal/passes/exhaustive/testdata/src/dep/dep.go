@@ -0,0 +1,25 @@
+package dep
+
+type E int
+
+const (
+	A E = iota
+	B
+	C
+)
+
+type I interface {
+	i()
+}
+
+type X struct{}
+
+func (X) i() {}
+
+type Y struct{}
+
+func (Y) i() {}
+
+type Z struct{}
+
+func (Z) i() {}
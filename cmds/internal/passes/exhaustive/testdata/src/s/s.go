@@ -0,0 +1,98 @@
+package s
+
+import "dep"
+
+type E int
+
+const (
+	A E = iota
+	B
+	C
+)
+
+type I interface {
+	i()
+}
+
+type X struct{}
+
+func (X) i() {}
+
+type Y struct{}
+
+func (*Y) i() {}
+
+func ruledOut(e E) {
+	if e == A {
+		return
+	}
+	switch e {
+	case A: // want `case A of E is unreachable: it is ruled out by an earlier check`
+	case B, C:
+	}
+}
+
+func narrowed(e E) {
+	if e == A || e == B {
+		return
+	}
+	switch e {
+	case C:
+	}
+}
+
+func onlyOne(e E) {
+	if e != C {
+		return
+	}
+	switch e {
+	case C:
+	}
+}
+
+func stillMissing(e E) {
+	if e == A {
+		return
+	}
+	switch e { // want `switch over E is missing cases for C`
+	case B:
+	}
+}
+
+func notDominating(e E, b bool) {
+	if b {
+		if e == A {
+			return
+		}
+	}
+	switch e { // want `switch over E is missing cases for A`
+	case B, C:
+	}
+}
+
+func types(i I) {
+	if _, ok := i.(X); ok {
+		return
+	}
+	switch i.(type) {
+	case *Y:
+	}
+
+	switch i.(type) {
+	case X: // want `case X of I is unreachable: it is ruled out by an earlier check`
+	case *Y:
+	}
+}
+
+func multiple(i dep.I) {
+	switch i.(type) { // want `switch over I is missing cases for dep.Z`
+	case dep.X, dep.Y:
+	}
+
+	if _, ok := i.(dep.Z); ok {
+		return
+	}
+	switch i.(type) {
+	case dep.X, dep.Y:
+	}
+}
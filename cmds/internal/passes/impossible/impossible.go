@@ -0,0 +1,127 @@
+//Package impossible defines an Analyzer that reports type assertions
+//and type switch cases on closed sums that can never succeed.
+package impossible
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedtypes"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "impossible",
+	Doc: `report impossible type assertions and cases on closed sums
+
+The members of a closed interface, or an empty sum, are known.
+This reports type assertions and type switch cases on a value of the sum
+whose type is not a member, such as
+	* a type that only exists to be embedded in members
+	* an interface that no member implements
+as such code is dead.
+
+Other types that implement a closed interface are not reported,
+as a value of one, such as *A when A is a member,
+or a type embedding a member, can be a value of the interface.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, closedtypes.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cts := pass.ResultOf[closedtypes.Analyzer].(*closedtypes.Result)
+	info := pass.TypesInfo
+	str := func(T types.Type) string {
+		return types.TypeString(T, types.RelativeTo(pass.Pkg))
+	}
+
+	check := func(x, typ ast.Expr) {
+		T := info.TypeOf(typ)
+		if T == nil {
+			return
+		}
+		switch ct := cts.Of(info.TypeOf(x)).(type) {
+		case *closed.Interface:
+			if msg := interfaceCase(ct, T, str); msg != "" {
+				pass.Reportf(typ.Pos(), "%s", msg)
+			}
+		case *closed.EmptySum:
+			if !memberOf(ct.Members, T) {
+				pass.Reportf(typ.Pos(), "%s can never be a value of %s", str(T), name(ct))
+			}
+		}
+	}
+
+	filter := []ast.Node{
+		(*ast.TypeAssertExpr)(nil),
+		(*ast.TypeSwitchStmt)(nil),
+	}
+	inspect.Preorder(filter, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.TypeAssertExpr:
+			//x.(type) is handled with its switch
+			if n.Type != nil {
+				check(n.X, n.Type)
+			}
+
+		case *ast.TypeSwitchStmt:
+			var x ast.Expr
+			switch a := n.Assign.(type) {
+			case *ast.AssignStmt:
+				x = a.Rhs[0].(*ast.TypeAssertExpr).X
+			case *ast.ExprStmt:
+				x = a.X.(*ast.TypeAssertExpr).X
+			}
+			for _, cc := range n.Body.List {
+				for _, typ := range cc.(*ast.CaseClause).List {
+					if id, ok := typ.(*ast.Ident); ok && id.Name == "nil" {
+						continue
+					}
+					check(x, typ)
+				}
+			}
+		}
+	})
+
+	return nil, nil
+}
+
+func name(t closed.Type) string {
+	return t.Types()[0].Name()
+}
+
+//interfaceCase returns why T can never be a value of ct or "".
+func interfaceCase(ct *closed.Interface, T types.Type, str func(types.Type) string) string {
+	if types.IsInterface(T) {
+		I := T.Underlying().(*types.Interface)
+		for _, m := range ct.Members {
+			if types.Implements(m.Type, I) {
+				return ""
+			}
+		}
+		return "no member of " + name(ct) + " implements " + str(T)
+	}
+
+	for _, m := range ct.FalseMembers {
+		if types.Identical(m.Type, T) {
+			return str(T) + " is only embedded in the members of " + name(ct) + " and is never a value of it"
+		}
+	}
+	return ""
+}
+
+func memberOf(ms []types.Type, T types.Type) bool {
+	for _, m := range ms {
+		if types.Identical(m, T) {
+			return true
+		}
+		if types.IsInterface(T) && types.Implements(m, T.Underlying().(*types.Interface)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package impossible_test
+
+import (
+	"testing"
+
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/impossible"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), impossible.Analyzer, "a")
+}
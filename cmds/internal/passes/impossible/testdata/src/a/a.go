@@ -0,0 +1,31 @@
+package a
+
+import (
+	"encoding/json"
+	"io"
+	"sum"
+)
+
+//Wrapped implements sum.Node by embedding a member.
+type Wrapped struct {
+	sum.Lit
+}
+
+func cases(n sum.Node, tok json.Token) {
+	switch n.(type) {
+	case nil:
+	case sum.Lit, *sum.Call:
+	case *sum.Lit, Wrapped:
+	case sum.Stringer:
+	case io.Reader: // want `no member of Node implements io.Reader`
+	}
+
+	_ = n.(*sum.Call)
+	_, _ = n.(*sum.Lit)
+	_, _ = n.(*Wrapped)
+
+	switch tok.(type) {
+	case json.Delim, string, bool:
+	case int: // want `int can never be a value of Token`
+	}
+}
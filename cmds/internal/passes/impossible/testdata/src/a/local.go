@@ -0,0 +1,26 @@
+package a
+
+type Expr interface {
+	isExpr()
+}
+
+type expr struct{}
+
+func (expr) isExpr() {}
+
+type Num struct {
+	expr
+	N int
+}
+
+type Neg struct {
+	expr
+	X Expr
+}
+
+func local(e Expr) {
+	switch e.(type) {
+	case Num, Neg:
+	case expr: // want `expr is only embedded in the members of Expr and is never a value of it`
+	}
+}
@@ -0,0 +1,28 @@
+package sum
+
+type Node interface {
+	isNode()
+	Pos() int
+}
+
+type node struct{}
+
+func (node) isNode() {}
+
+type Lit struct {
+	node
+}
+
+func (Lit) Pos() int { return 0 }
+
+type Call struct {
+	node
+}
+
+func (*Call) Pos() int { return 0 }
+
+type Stringer interface {
+	String() string
+}
+
+func (Lit) String() string { return "" }
@@ -0,0 +1,298 @@
+//Package optional defines an Analyzer that reports uses of the value
+//of an optional struct that do not respect its discriminant.
+package optional
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedtypes"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/passutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "optional",
+	Doc: `report unchecked uses of the value of an optional struct
+
+An optional struct, such as sql.NullString, has a value field
+that is only meaningful when its discriminant is true.
+
+This reports reads of the value, like x.String,
+that are not guarded by a check that x.Valid is true,
+either by an enclosing if, case, or && or by an earlier
+	if !x.Valid {
+		return
+	}
+It also reports assignments to the value
+that are not accompanied by setting the discriminant to true,
+including in struct literals.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, closedtypes.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cts := pass.ResultOf[closedtypes.Analyzer].(*closedtypes.Result)
+	info := pass.TypesInfo
+
+	optionalOf := func(T types.Type) *closed.OptionalStruct {
+		if T == nil {
+			return nil
+		}
+		if p, ok := T.Underlying().(*types.Pointer); ok {
+			T = p.Elem()
+		}
+		o, _ := cts.Of(T).(*closed.OptionalStruct)
+		return o
+	}
+
+	filter := []ast.Node{
+		(*ast.SelectorExpr)(nil),
+		(*ast.CompositeLit)(nil),
+	}
+	inspect.WithStack(filter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			o := optionalOf(info.TypeOf(n.X))
+			if o == nil || n.Sel.Name != o.Field.Name() {
+				return true
+			}
+			x := key(info, n.X)
+			if x == "" {
+				return true
+			}
+			c := &guard{info: info, x: x, disc: o.Discriminant.Name()}
+
+			switch p := stack[len(stack)-2].(type) {
+			case *ast.UnaryExpr:
+				if p.Op == token.AND {
+					//the value is likely being set
+					return true
+				}
+			case *ast.AssignStmt:
+				if p.Tok == token.ASSIGN && isLHS(p, n) {
+					if !c.guarded(stack) && !c.setsTrue(stack) {
+						pass.Reportf(n.Pos(), "%s.%s set without setting %s.%s to true", x, o.Field.Name(), x, c.disc)
+					}
+					return true
+				}
+			}
+			if !c.guarded(stack) {
+				pass.Reportf(n.Pos(), "%s.%s read without checking %s.%s", x, o.Field.Name(), x, c.disc)
+			}
+
+		case *ast.CompositeLit:
+			o := optionalOf(info.TypeOf(n))
+			if o == nil {
+				return true
+			}
+			field, disc := literal(n, o)
+			if field != nil && !isZero(info, field) && (disc == nil || isFalse(info, disc)) {
+				pass.Reportf(n.Pos(), "literal sets %s without setting %s to true", o.Field.Name(), o.Discriminant.Name())
+			}
+		}
+		return true
+	})
+
+	return nil, nil
+}
+
+//key returns a string identifying x
+//if it is an identifier or a chain of selectors on an identifier.
+func key(info *types.Info, x ast.Expr) string {
+	switch x := ast.Unparen(x).(type) {
+	case *ast.Ident:
+		if info.ObjectOf(x) == nil {
+			return ""
+		}
+		return x.Name
+	case *ast.SelectorExpr:
+		if k := key(info, x.X); k != "" {
+			return k + "." + x.Sel.Name
+		}
+	case *ast.StarExpr:
+		return key(info, x.X)
+	}
+	return ""
+}
+
+func isLHS(a *ast.AssignStmt, x ast.Expr) bool {
+	for _, l := range a.Lhs {
+		if l == x {
+			return true
+		}
+	}
+	return false
+}
+
+//guard determines whether x.disc is known to be true.
+type guard struct {
+	info    *types.Info
+	x, disc string
+}
+
+//isDisc reports whether e is x.disc.
+func (g *guard) isDisc(e ast.Expr) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == g.disc && key(g.info, sel.X) == g.x
+}
+
+//when reports whether cond evaluating to truth implies that x.disc is true.
+func (g *guard) when(cond ast.Expr, truth bool) bool {
+	switch c := ast.Unparen(cond).(type) {
+	case *ast.SelectorExpr:
+		return truth && g.isDisc(c)
+	case *ast.UnaryExpr:
+		return c.Op == token.NOT && g.when(c.X, !truth)
+	case *ast.BinaryExpr:
+		switch c.Op {
+		case token.LAND:
+			return truth && (g.when(c.X, true) || g.when(c.Y, true))
+		case token.LOR:
+			return !truth && (g.when(c.X, false) || g.when(c.Y, false))
+		}
+	}
+	return false
+}
+
+//guarded reports whether the top of stack is only evaluated when x.disc is true.
+func (g *guard) guarded(stack []ast.Node) bool {
+	for i := len(stack) - 1; i > 0; i-- {
+		child, parent := stack[i], stack[i-1]
+		switch p := parent.(type) {
+		case *ast.BinaryExpr:
+			if p.Y == child {
+				if p.Op == token.LAND && g.when(p.X, true) {
+					return true
+				}
+				if p.Op == token.LOR && g.when(p.X, false) {
+					return true
+				}
+			}
+
+		case *ast.IfStmt:
+			if p.Body == child && g.when(p.Cond, true) {
+				return true
+			}
+			if p.Else == child && g.when(p.Cond, false) {
+				return true
+			}
+
+		case *ast.CaseClause:
+			if len(p.List) == 1 && p.List[0] != child && i >= 3 && g.when(p.List[0], true) {
+				//only a tagless switch has boolean cases
+				if sw, ok := stack[i-3].(*ast.SwitchStmt); ok && sw.Tag == nil {
+					return true
+				}
+			}
+		}
+
+		//an earlier if that leaves when x.disc is false
+		var list []ast.Stmt
+		switch p := parent.(type) {
+		case *ast.BlockStmt:
+			list = p.List
+		case *ast.CaseClause:
+			list = p.Body
+		case *ast.CommClause:
+			list = p.Body
+		}
+		for _, s := range list {
+			if s == child {
+				break
+			}
+			if is, ok := s.(*ast.IfStmt); ok && is.Else == nil && terminates(is.Body) && g.when(is.Cond, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//setsTrue reports whether the statements containing the top of stack
+//also set x.disc to true.
+func (g *guard) setsTrue(stack []ast.Node) bool {
+	list, _, ok := passutil.StmtList(stack)
+	if !ok {
+		return false
+	}
+	for _, s := range list {
+		a, ok := s.(*ast.AssignStmt)
+		if !ok || a.Tok != token.ASSIGN || len(a.Lhs) != len(a.Rhs) {
+			continue
+		}
+		for i, l := range a.Lhs {
+			if g.isDisc(l) && !isFalse(g.info, a.Rhs[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//terminates reports whether b always leaves the enclosing block.
+func terminates(b *ast.BlockStmt) bool {
+	if len(b.List) == 0 {
+		return false
+	}
+	switch s := b.List[len(b.List)-1].(type) {
+	case *ast.ReturnStmt, *ast.BranchStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		id, ok := call.Fun.(*ast.Ident)
+		return ok && id.Name == "panic"
+	}
+	return false
+}
+
+//literal returns the expressions for the value and discriminant in lit, if set.
+func literal(lit *ast.CompositeLit, o *closed.OptionalStruct) (field, disc ast.Expr) {
+	s := o.Types()[0].Type().Underlying().(*types.Struct)
+	for i, e := range lit.Elts {
+		var nm string
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			id, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			nm, e = id.Name, kv.Value
+		} else if i < s.NumFields() {
+			nm = s.Field(i).Name()
+		}
+		switch nm {
+		case o.Field.Name():
+			field = e
+		case o.Discriminant.Name():
+			disc = e
+		}
+	}
+	return field, disc
+}
+
+func isFalse(info *types.Info, x ast.Expr) bool {
+	v := info.Types[x].Value
+	return v != nil && v.Kind() == constant.Bool && !constant.BoolVal(v)
+}
+
+func isZero(info *types.Info, x ast.Expr) bool {
+	if id, ok := x.(*ast.Ident); ok {
+		if _, ok := info.Uses[id].(*types.Nil); ok {
+			return true
+		}
+	}
+	v := info.Types[x].Value
+	return v != nil && passutil.IsZeroConst(v)
+}
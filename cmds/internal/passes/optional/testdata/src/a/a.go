@@ -0,0 +1,63 @@
+package a
+
+import "database/sql"
+
+func use(string) {}
+
+func reads(x sql.NullString, p *sql.NullInt64) {
+	use(x.String) // want `x.String read without checking x.Valid`
+	if x.Valid {
+		use(x.String)
+	}
+	if !x.Valid {
+		use(x.String) // want `x.String read without checking x.Valid`
+	} else {
+		use(x.String)
+	}
+	if x.Valid && x.String != "" {
+	}
+	_ = !x.Valid || x.String == ""
+	switch {
+	case x.Valid:
+		use(x.String)
+	}
+	if p.Valid {
+		_ = p.Int64
+	}
+	_ = p.Int64 // want `p.Int64 read without checking p.Valid`
+}
+
+func early(x sql.NullString) string {
+	if !x.Valid {
+		return ""
+	}
+	return x.String
+}
+
+type row struct {
+	Name sql.NullString
+}
+
+func nested(r row) {
+	if r.Name.Valid {
+		use(r.Name.String)
+	}
+	use(r.Name.String) // want `r.Name.String read without checking r.Name.Valid`
+}
+
+func writes(s string) (sql.NullString, sql.NullString) {
+	var x, y sql.NullString
+	x.String = s
+	x.Valid = true
+	y.String = s // want `y.String set without setting y.Valid to true`
+	return x, y
+}
+
+func literals(s string) {
+	_ = sql.NullString{String: s, Valid: true}
+	_ = sql.NullString{String: s} // want `literal sets String without setting Valid to true`
+	_ = sql.NullString{s, false}  // want `literal sets String without setting Valid to true`
+	_ = sql.NullString{}
+	_ = sql.NullString{String: ""}
+	_ = sql.Null[int]{V: 1} // want `literal sets V without setting Valid to true`
+}
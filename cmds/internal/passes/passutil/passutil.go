@@ -0,0 +1,148 @@
+//Package passutil contains helpers shared by the analyzers for closed types.
+package passutil
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+)
+
+//IsConversion reports whether call is a conversion to a type.
+func IsConversion(info *types.Info, call *ast.CallExpr) bool {
+	tv, ok := info.Types[call.Fun]
+	return ok && tv.IsType() && len(call.Args) == 1
+}
+
+//IsLabel reports whether v is the value of a label of t.
+func IsLabel(t *closed.Enum, v constant.Value) bool {
+	for _, L := range t.Labels {
+		if constant.Compare(L[0].Val(), token.EQL, v) {
+			return true
+		}
+	}
+	return false
+}
+
+//ZeroAllowed reports whether the zero value is a legal value of t.
+func ZeroAllowed(t *closed.Enum) bool {
+	return !t.NonZero || closedutil.ContainsLabeledZero(t)
+}
+
+//LegalConst reports whether the constant v is a legal value of t,
+//which must be a *closed.Enum or *closed.Bitset.
+func LegalConst(t closed.Type, v constant.Value) bool {
+	switch t := t.(type) {
+	case *closed.Enum:
+		if IsLabel(t, v) {
+			return true
+		}
+		return ZeroAllowed(t) && IsZeroConst(v)
+	case *closed.Bitset:
+		u, ok := constant.Uint64Val(constant.ToInt(v))
+		return ok && u&^closedutil.AllMask(t) == 0
+	}
+	return true
+}
+
+//IsZeroConst reports whether v is the zero value of its kind.
+func IsZeroConst(v constant.Value) bool {
+	switch v.Kind() {
+	case constant.Bool:
+		return !constant.BoolVal(v)
+	case constant.String:
+		return constant.StringVal(v) == ""
+	case constant.Int, constant.Float, constant.Complex:
+		return constant.Sign(v) == 0
+	}
+	return false
+}
+
+//ObjectOf returns the object of x if it is an identifier, or nil.
+func ObjectOf(info *types.Info, x ast.Expr) types.Object {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	return info.ObjectOf(id)
+}
+
+//IsValidation reports whether call validates v:
+//that is, whether it is a method call on v or a call of a func with v as its only argument
+//that returns only an error, such as the validators generated by clvalid.
+func IsValidation(info *types.Info, call *ast.CallExpr, v types.Object) bool {
+	sig, ok := info.TypeOf(call.Fun).(*types.Signature)
+	if !ok || !returnsOnlyError(sig) {
+		return false
+	}
+	if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok && len(call.Args) == 0 {
+		if s := info.Selections[sel]; s != nil && s.Kind() == types.MethodVal {
+			return ObjectOf(info, sel.X) == v
+		}
+	}
+	return len(call.Args) == 1 && ObjectOf(info, call.Args[0]) == v
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func returnsOnlyError(sig *types.Signature) bool {
+	r := sig.Results()
+	return r.Len() == 1 && types.Identical(r.At(0).Type(), errorType)
+}
+
+//StmtList returns the list of statements that contains the innermost statement of stack
+//and the index of that statement within the list.
+//
+//The stack is as provided by inspector.WithStack, with the outermost node first.
+func StmtList(stack []ast.Node) (list []ast.Stmt, at int, ok bool) {
+	for i := len(stack) - 1; i > 0; i-- {
+		s, isStmt := stack[i].(ast.Stmt)
+		if !isStmt {
+			continue
+		}
+		switch p := stack[i-1].(type) {
+		case *ast.BlockStmt:
+			list = p.List
+		case *ast.CaseClause:
+			list = p.Body
+		case *ast.CommClause:
+			list = p.Body
+		default:
+			continue
+		}
+		for j, t := range list {
+			if t == s {
+				return list, j, true
+			}
+		}
+		return nil, 0, false
+	}
+	return nil, 0, false
+}
+
+//Unconditionally calls f on each node of n that is evaluated whenever n is,
+//skipping nested blocks, clauses, and function literals.
+func Unconditionally(n ast.Node, f func(ast.Node) bool) {
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause, *ast.FuncLit:
+			return false
+		case *ast.IfStmt:
+			//the else branch is conditional even if it is an if statement
+			if !f(n) {
+				return false
+			}
+			if n.Init != nil {
+				ast.Inspect(n.Init, visit)
+			}
+			ast.Inspect(n.Cond, visit)
+			return false
+		}
+		return f(n)
+	}
+	ast.Inspect(n, visit)
+}
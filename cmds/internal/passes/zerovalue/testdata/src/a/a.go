@@ -0,0 +1,99 @@
+package a
+
+import "e"
+
+type T struct {
+	K e.Kind
+	S e.Sum
+	P e.Plain
+}
+
+func explicit() (e.Sum, e.Kind) {
+	var s e.Sum = nil // want `nil is not a legal value of Sum`
+	_ = s
+	_ = e.Kind(0)    // want `the zero value is not a legal value of Kind`
+	var k e.Kind = 0 // want `the zero value is not a legal value of Kind`
+	_ = k
+	_ = e.Plain(0)
+	return nil, 0 // want `nil is not a legal value of Sum` `the zero value is not a legal value of Kind`
+}
+
+func compare(s e.Sum, k e.Kind) bool {
+	switch s.(type) {
+	case nil:
+	}
+	switch k {
+	case 0:
+	}
+	return s == nil || k != 0
+}
+
+func operands(k e.Kind) e.Kind {
+	if k > 0 || k <= 0 {
+		return k
+	}
+	if k+0 == e.K1 {
+		return k * 0
+	}
+	k += 0
+	use(0)                // want `the zero value is not a legal value of Kind`
+	_ = []e.Kind{e.K1, 0} // want `the zero value is not a legal value of Kind`
+	_ = int(e.Kind(0))    // want `the zero value is not a legal value of Kind`
+	_ = e.Kind(int(0))    // want `the zero value is not a legal value of Kind`
+	return k
+}
+
+func literals() {
+	_ = T{}        // want `literal omits field K` `literal omits field S`
+	_ = T{K: e.K1} // want `literal omits field S: nil is not a legal value of Sum`
+	_ = T{K: e.K1, S: e.S1{}}
+	_ = T{e.K2, e.S2{}, 0}
+	_ = T{K: e.K1, S: nil} // want `nil is not a legal value of Sum`
+}
+
+func use(e.Kind) {}
+
+func unassigned() {
+	var k e.Kind // want `k may be used while it is the zero value, which is not a legal value of Kind`
+	use(k)
+}
+
+func assigned(b bool, n int) {
+	var k e.Kind
+	if b {
+		k = e.K1
+	} else {
+		k = e.K2
+	}
+	use(k)
+
+	var j e.Kind
+	switch n {
+	case 1:
+		j = e.K1
+	default:
+		j = e.K2
+	}
+	use(j)
+
+	var i e.Kind
+	if b {
+		return
+	}
+	i = e.K1
+	use(i)
+}
+
+func partial(b bool) {
+	var k e.Kind // want `k may be used while it is the zero value`
+	if b {
+		k = e.K1
+	}
+	use(k)
+
+	var s e.Sum // want `s may be used while it is nil`
+	for i := 0; i < 3; i++ {
+		s = e.S1{}
+	}
+	_ = s
+}
@@ -0,0 +1,33 @@
+package e
+
+// Kind of thing.
+//
+//closed:nonzero
+type Kind int
+
+const (
+	K1 Kind = iota + 1
+	K2
+)
+
+//closed:nonnil
+type Sum interface {
+	sum()
+	String() string
+}
+
+type S1 struct{}
+type S2 struct{}
+
+func (S1) sum()           {}
+func (S1) String() string { return "" }
+func (S2) sum()           {}
+func (S2) String() string { return "" }
+
+// Plain allows zero.
+type Plain int
+
+const (
+	P1 Plain = iota + 1
+	P2
+)
@@ -0,0 +1,525 @@
+//Package zerovalue defines an Analyzer that reports uses of the zero value
+//of closed types that forbid it.
+package zerovalue
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/closedtypes"
+	"github.com/jimmyfrasche/closed/cmds/internal/passes/passutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "zerovalue",
+	Doc: `report zero values of closed types that forbid them
+
+An enum whose doc comment contains the directive
+	//closed:nonzero
+and has no label for zero may not be zero.
+An interface whose doc comment contains the directive
+	//closed:nonnil
+may not be nil.
+
+For such types, this reports
+	* explicit zero values, such as E(0) or nil, used as a value:
+	  assigned, returned, passed, converted, or in a composite literal
+	* variables declared without a value that may be used before they are assigned
+	* struct literals that omit a field of the type.
+
+Other uses of the zero value, as in comparisons, arithmetic, and cases, are allowed.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, closedtypes.Analyzer},
+	Run:      run,
+}
+
+//forbidden returns a description of the zero value of T
+//if it is not a legal value of T.
+func forbidden(cts *closedtypes.Result, T types.Type) (zero, name string, ok bool) {
+	switch ct := cts.Of(T).(type) {
+	case *closed.Enum:
+		if !passutil.ZeroAllowed(ct) {
+			return "the zero value", ct.Types()[0].Name(), true
+		}
+	case *closed.Interface:
+		if ct.NonNil {
+			return "nil", ct.Types()[0].Name(), true
+		}
+	}
+	return "", "", false
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cts := pass.ResultOf[closedtypes.Analyzer].(*closedtypes.Result)
+	info := pass.TypesInfo
+
+	filter := []ast.Node{
+		(*ast.Ident)(nil),
+		(*ast.BasicLit)(nil),
+		(*ast.CallExpr)(nil),
+		(*ast.CompositeLit)(nil),
+		(*ast.ValueSpec)(nil),
+	}
+	inspect.WithStack(filter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.Ident, *ast.BasicLit, *ast.CallExpr:
+			x := n.(ast.Expr)
+			if !isZero(info, x) || !usedAsValue(info, x, stack) || inConst(stack) {
+				return true
+			}
+			if zero, name, ok := forbidden(cts, typeOf(info, x, stack)); ok {
+				pass.Reportf(x.Pos(), "%s is not a legal value of %s", zero, name)
+			}
+
+		case *ast.CompositeLit:
+			for _, f := range omitted(info, n) {
+				if zero, name, ok := forbidden(cts, f.Type()); ok {
+					pass.Reportf(n.Pos(), "literal omits field %s: %s is not a legal value of %s", f.Name(), zero, name)
+				}
+			}
+
+		case *ast.ValueSpec:
+			if len(n.Values) != 0 || isPackageLevel(stack) {
+				return true
+			}
+			list, at, ok := passutil.StmtList(stack)
+			if !ok {
+				return true
+			}
+			for _, nm := range n.Names {
+				v := info.Defs[nm]
+				if v == nil {
+					continue
+				}
+				zero, name, ok := forbidden(cts, v.Type())
+				if !ok {
+					continue
+				}
+				c := &checker{info: info, v: v}
+				if c.stmts(list[at+1:]) == read {
+					pass.Reportf(nm.Pos(), "%s may be used while it is %s, which is not a legal value of %s", nm.Name, zero, name)
+				}
+			}
+		}
+		return true
+	})
+
+	return nil, nil
+}
+
+//isZero reports whether x is nil or a constant zero.
+func isZero(info *types.Info, x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		switch info.Uses[x].(type) {
+		case *types.Nil:
+			return true
+		case *types.Const:
+		default:
+			return false
+		}
+	case *ast.CallExpr:
+		if !passutil.IsConversion(info, x) {
+			return false
+		}
+	}
+	tv := info.Types[x]
+	return tv.Value != nil && passutil.IsZeroConst(tv.Value)
+}
+
+//typeOf x, the top of stack.
+//As the type of nil is not recorded, it is found from its context.
+func typeOf(info *types.Info, x ast.Expr, stack []ast.Node) types.Type {
+	if T := info.TypeOf(x); !types.Identical(T, types.Typ[types.UntypedNil]) {
+		return T
+	}
+
+	indexOf := func(xs []ast.Expr) int {
+		for i, y := range xs {
+			if y == x {
+				return i
+			}
+		}
+		return -1
+	}
+
+	switch p := stack[len(stack)-2].(type) {
+	case *ast.AssignStmt:
+		if i := indexOf(p.Rhs); i >= 0 && len(p.Lhs) == len(p.Rhs) {
+			return info.TypeOf(p.Lhs[i])
+		}
+
+	case *ast.ValueSpec:
+		if p.Type != nil {
+			return info.TypeOf(p.Type)
+		}
+
+	case *ast.KeyValueExpr:
+		if p.Value == x {
+			return info.TypeOf(p.Key)
+		}
+
+	case *ast.CallExpr:
+		sig, ok := info.TypeOf(p.Fun).(*types.Signature)
+		i := indexOf(p.Args)
+		if !ok || i < 0 {
+			return nil
+		}
+		ps := sig.Params()
+		if sig.Variadic() && i >= ps.Len()-1 {
+			if s, ok := ps.At(ps.Len() - 1).Type().(*types.Slice); ok && !p.Ellipsis.IsValid() {
+				return s.Elem()
+			}
+			return nil
+		}
+		return ps.At(i).Type()
+
+	case *ast.ReturnStmt:
+		i := indexOf(p.Results)
+		if i < 0 {
+			return nil
+		}
+		for j := len(stack) - 1; j >= 0; j-- {
+			var sig *types.Signature
+			switch f := stack[j].(type) {
+			case *ast.FuncDecl:
+				if o := info.Defs[f.Name]; o != nil {
+					sig, _ = o.Type().(*types.Signature)
+				}
+			case *ast.FuncLit:
+				sig, _ = info.TypeOf(f).(*types.Signature)
+			default:
+				continue
+			}
+			if sig == nil || sig.Results().Len() != len(p.Results) {
+				return nil
+			}
+			return sig.Results().At(i).Type()
+		}
+	}
+	return nil
+}
+
+//usedAsValue reports whether x, the top of stack, is used as a value:
+//assigned, returned, passed, converted, or an element of a composite literal.
+//
+//The operand of a conversion to its own type, as the 0 in E(0),
+//is not, as only the conversion is considered.
+func usedAsValue(info *types.Info, x ast.Expr, stack []ast.Node) bool {
+	switch p := stack[len(stack)-2].(type) {
+	case *ast.AssignStmt:
+		//op assignments are arithmetic
+		return p.Tok == token.ASSIGN || p.Tok == token.DEFINE
+	case *ast.ValueSpec, *ast.ReturnStmt, *ast.CompositeLit:
+		return true
+	case *ast.KeyValueExpr:
+		return p.Value == x
+	case *ast.CallExpr:
+		if p.Fun == x {
+			return false
+		}
+		if passutil.IsConversion(info, p) {
+			return !types.Identical(info.TypeOf(x), info.TypeOf(p))
+		}
+		return true
+	}
+	return false
+}
+
+//inConst reports whether the top of stack is in a const declaration,
+//where the labels are defined.
+func inConst(stack []ast.Node) bool {
+	for _, n := range stack {
+		if g, ok := n.(*ast.GenDecl); ok && g.Tok == token.CONST {
+			return true
+		}
+	}
+	return false
+}
+
+func isPackageLevel(stack []ast.Node) bool {
+	for _, n := range stack {
+		if _, ok := n.(*ast.FuncDecl); ok {
+			return false
+		}
+	}
+	return true
+}
+
+//omitted returns the fields of a struct literal that are not set.
+func omitted(info *types.Info, lit *ast.CompositeLit) []*types.Var {
+	T := info.TypeOf(lit)
+	if T == nil {
+		return nil
+	}
+	s, ok := T.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	//unkeyed literals must set every field
+	if len(lit.Elts) > 0 {
+		if _, ok := lit.Elts[0].(*ast.KeyValueExpr); !ok {
+			return nil
+		}
+	}
+
+	set := map[string]bool{}
+	for _, e := range lit.Elts {
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			if id, ok := kv.Key.(*ast.Ident); ok {
+				set[id.Name] = true
+			}
+		}
+	}
+
+	var acc []*types.Var
+	for i := 0; i < s.NumFields(); i++ {
+		if f := s.Field(i); !set[f.Name()] {
+			acc = append(acc, f)
+		}
+	}
+	return acc
+}
+
+//state of a variable along the paths through some statements.
+type state int
+
+const (
+	//unknown means the variable is neither read nor definitely assigned.
+	unknown state = iota
+	//assigned means the variable is assigned, or the path ends, before it is read.
+	assigned
+	//read means the variable may be read before it is assigned.
+	read
+)
+
+//checker finds whether v may be read before it is assigned.
+type checker struct {
+	info *types.Info
+	v    types.Object
+}
+
+func (c *checker) stmts(list []ast.Stmt) state {
+	for _, s := range list {
+		if st := c.stmt(s); st != unknown {
+			return st
+		}
+	}
+	return unknown
+}
+
+//join the states of the branches of a statement.
+//If exhaustive, one of the branches is always taken.
+func join(exhaustive bool, branches ...state) state {
+	all := exhaustive
+	for _, b := range branches {
+		if b == read {
+			return read
+		}
+		if b != assigned {
+			all = false
+		}
+	}
+	if all {
+		return assigned
+	}
+	return unknown
+}
+
+func (c *checker) stmt(s ast.Stmt) state {
+	switch s := s.(type) {
+	case nil:
+		return unknown
+
+	case *ast.BlockStmt:
+		return c.stmts(s.List)
+
+	case *ast.LabeledStmt:
+		return c.stmt(s.Stmt)
+
+	case *ast.ReturnStmt:
+		if c.expr(s) == read {
+			return read
+		}
+		return assigned
+
+	case *ast.BranchStmt:
+		//control leaves this path
+		return assigned
+
+	case *ast.IfStmt:
+		if st := c.stmt(s.Init); st != unknown {
+			return st
+		}
+		if c.expr(s.Cond) == read {
+			return read
+		}
+		return join(s.Else != nil, c.stmt(s.Body), c.stmt(s.Else))
+
+	case *ast.SwitchStmt:
+		if st := c.stmt(s.Init); st != unknown {
+			return st
+		}
+		if s.Tag != nil && c.expr(s.Tag) == read {
+			return read
+		}
+		return c.clauses(s.Body)
+
+	case *ast.TypeSwitchStmt:
+		if st := c.stmt(s.Init); st != unknown {
+			return st
+		}
+		if c.stmt(s.Assign) == read {
+			return read
+		}
+		return c.clauses(s.Body)
+
+	case *ast.SelectStmt:
+		var bs []state
+		for _, cc := range s.Body.List {
+			cc := cc.(*ast.CommClause)
+			st := c.stmt(cc.Comm)
+			if st == unknown {
+				st = c.stmts(cc.Body)
+			}
+			bs = append(bs, st)
+		}
+		return join(true, bs...)
+
+	case *ast.ForStmt:
+		if st := c.stmt(s.Init); st != unknown {
+			return st
+		}
+		if s.Cond != nil && c.expr(s.Cond) == read {
+			return read
+		}
+		//the body may not run
+		if c.stmt(s.Body) == read || c.stmt(s.Post) == read {
+			return read
+		}
+		return unknown
+
+	case *ast.RangeStmt:
+		if c.expr(s.X) == read {
+			return read
+		}
+		if c.stmt(s.Body) == read {
+			return read
+		}
+		return unknown
+
+	case *ast.AssignStmt:
+		if c.expr(exprs(s.Rhs)) == read {
+			return read
+		}
+		for _, x := range s.Lhs {
+			if passutil.ObjectOf(c.info, x) == c.v {
+				if s.Tok == token.ASSIGN || s.Tok == token.DEFINE {
+					return assigned
+				}
+				//op assignments read
+				return read
+			}
+		}
+		return c.expr(exprs(s.Lhs))
+	}
+
+	return c.expr(s)
+}
+
+//clauses joins the states of each clause of a switch.
+func (c *checker) clauses(body *ast.BlockStmt) state {
+	hasDefault := false
+	var bs []state
+	for _, cc := range body.List {
+		cc := cc.(*ast.CaseClause)
+		if cc.List == nil {
+			hasDefault = true
+		}
+		for _, x := range cc.List {
+			if c.expr(x) == read {
+				return read
+			}
+		}
+		bs = append(bs, c.stmts(cc.Body))
+	}
+	return join(hasDefault, bs...)
+}
+
+//exprs wraps a list of expressions as a node.
+type exprs []ast.Expr
+
+func (xs exprs) Pos() token.Pos {
+	if len(xs) == 0 {
+		return token.NoPos
+	}
+	return xs[0].Pos()
+}
+
+func (xs exprs) End() token.Pos {
+	if len(xs) == 0 {
+		return token.NoPos
+	}
+	return xs[len(xs)-1].End()
+}
+
+//expr returns the state of v in n, which is evaluated without any control flow
+//that concerns us.
+//
+//Taking the address of v, or referencing it in a closure,
+//is treated as an assignment as we cannot follow it further.
+func (c *checker) expr(n ast.Node) state {
+	if n == nil {
+		return unknown
+	}
+	st := unknown
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		if st == read {
+			return false
+		}
+		switch n := n.(type) {
+		case exprs:
+			for _, x := range n {
+				ast.Inspect(x, visit)
+			}
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.AND && passutil.ObjectOf(c.info, n.X) == c.v {
+				st = assigned
+				return false
+			}
+		case *ast.FuncLit:
+			if c.mentions(n) {
+				st = assigned
+			}
+			return false
+		case *ast.Ident:
+			if c.info.Uses[n] == c.v {
+				st = read
+			}
+		}
+		return true
+	}
+	ast.Inspect(n, visit)
+	return st
+}
+
+func (c *checker) mentions(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && c.info.Uses[id] == c.v {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"fmt"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jimmyfrasche/closed"
+)
+
+//ClosedTypesInDir type checks the package in dir and returns its closed types.
+func ClosedTypesInDir(dir string) ([]closed.Type, error) {
+	bi, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fs, bi.Dir, MakeFileCheck(bi.GoFiles), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	files := FilesToSlice(pkgs[bi.Name])
+
+	cfg := types.Config{
+		Importer: importer.Default(),
+	}
+	pkg, err := cfg.Check(bi.ImportPath, fs, files, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return closed.InPackage(fs, files, pkg)
+}
+
+//Worktree checks out rev of the git repository containing dir into a temporary worktree
+//and returns the directory corresponding to dir within it
+//and a func that removes the worktree.
+func Worktree(rev, dir string) (string, func(), error) {
+	fail := func(err error) (string, func(), error) {
+		return "", nil, err
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fail(err)
+	}
+	top, err := git(abs, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return fail(err)
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return fail(err)
+	}
+
+	tmp, err := ioutil.TempDir("", "closed-worktree")
+	if err != nil {
+		return fail(err)
+	}
+	wt := filepath.Join(tmp, "old")
+	if _, err := git(top, "worktree", "add", "--detach", wt, rev); err != nil {
+		os.RemoveAll(tmp)
+		return fail(err)
+	}
+	cleanup := func() {
+		if _, err := git(top, "worktree", "remove", "--force", wt); err != nil {
+			log.Print(err)
+		}
+		os.RemoveAll(tmp)
+	}
+	return filepath.Join(wt, rel), cleanup, nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
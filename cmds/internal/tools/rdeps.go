@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"go/build"
+	"go/parser"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+//LoadWithRdeps loads the package imp and its reverse dependencies, with their tests,
+//using the default build context.
+//
+//Function bodies are only type checked for those packages.
+func LoadWithRdeps(imp string) (*loader.Program, error) {
+	rdeps, err := Rdeps(build.Default.BuildTags, imp)
+	if err != nil {
+		return nil, err
+	}
+
+	initial := map[string]bool{imp: true}
+	for _, p := range rdeps {
+		initial[p] = true
+	}
+
+	cfg := &loader.Config{
+		ParserMode: parser.ParseComments,
+		TypeCheckFuncBodies: func(p string) bool {
+			return initial[p] || initial[strings.TrimSuffix(p, "_test")]
+		},
+		Build: &build.Default,
+	}
+	for p := range initial {
+		cfg.ImportWithTests(p)
+	}
+	return cfg.Load()
+}
@@ -110,10 +110,15 @@ func Deps(buildTags []string, imp string) ([]string, error) {
 //Rdeps invokes rdeps on import path imp to gather its reverse dependencies.
 //
 //rdeps is part of honnef.co/go/tools/...
+//If it is not installed, the reverse dependencies are found
+//among the packages matched by "go list all".
 func Rdeps(buildTags []string, imp string) ([]string, error) {
 	if imp == "" {
 		return nil, errors.New("rdeps requires import path")
 	}
+	if _, err := exec.LookPath("rdeps"); err != nil {
+		return goListRdeps(buildTags, imp)
+	}
 	var args []string
 	if bt := unparseTagsFlag(buildTags); bt != "" {
 		args = append(args, bt)
@@ -122,6 +127,30 @@ func Rdeps(buildTags []string, imp string) ([]string, error) {
 	return execCollect(exec.Command("rdeps", args...))
 }
 
+func goListRdeps(buildTags []string, imp string) ([]string, error) {
+	args := []string{"list", "-e", "-f", "{{.ImportPath}}{{range .Deps}} {{.}}{{end}}"}
+	if bt := unparseTagsFlag(buildTags); bt != "" {
+		args = append(args, bt)
+	}
+	args = append(args, "--", "all")
+	out, err := execCollect(exec.Command("go", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []string
+	for _, line := range out {
+		fields := strings.Fields(line)
+		for _, dep := range fields[1:] {
+			if dep == imp {
+				pkgs = append(pkgs, fields[0])
+				break
+			}
+		}
+	}
+	return pkgs, nil
+}
+
 func execCollect(cmd *exec.Cmd) ([]string, error) {
 	cmd.Stderr = os.Stderr
 	r, err := cmd.StdoutPipe()
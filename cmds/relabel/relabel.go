@@ -0,0 +1,440 @@
+//Command relabel renames a label of an enum or bitset
+//in its package and its reverse dependencies,
+//	relabel example.com/pkg Kind OldName NewName
+//or, with -merge, merges a label of an enum into another,
+//	relabel -merge example.com/pkg Kind From Into
+//so that every use of From becomes a use of Into.
+//The declaration of From is replaced by _ so that the values
+//of the labels declared after it with iota do not change.
+//A use of From in the declaration of another constant, such as
+//	const Max = From
+//is an error, as the value of that constant would change.
+//
+//Merging labels can create duplicate cases in switches.
+//As only the first case could be taken after the merge,
+//the later duplicates are removed,
+//and a warning is printed if that removes a case with a body.
+//
+//With -keep, the old name is kept as a deprecated synonym,
+//so that packages outside of the reverse dependencies continue to build.
+//
+//Code generated for the type, such as by clvalid, is updated in the same way,
+//including the names of labels in string literals.
+//
+//The files that would change are printed.
+//With -w, they are also rewritten.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/jimmyfrasche/closed"
+	"github.com/jimmyfrasche/closed/cmds/internal/closedutil"
+	"github.com/jimmyfrasche/closed/cmds/internal/tools"
+	"golang.org/x/tools/go/loader"
+)
+
+func failOn(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	log.SetPrefix(fmt.Sprintf("%s: ", os.Args[0]))
+	log.SetFlags(0)
+
+	tools.AddTagsFlagDefault()
+	var save, keep, merge bool
+	flag.BoolVar(&save, "w", false, "write the changed files")
+	flag.BoolVar(&keep, "keep", false, "keep the old name as a deprecated synonym")
+	flag.BoolVar(&merge, "merge", false, "merge the first label into the second")
+
+	flag.Usage = func() {
+		log.SetPrefix("")
+		log.Printf("usage: %s [flags] importPath Type Old New", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 4 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	imp, typ, old, new := flag.Arg(0), flag.Arg(1), flag.Arg(2), flag.Arg(3)
+
+	prog, err := tools.LoadWithRdeps(imp)
+	failOn(err)
+
+	r, err := newRelabeler(prog, imp, typ, old, new, merge)
+	failOn(err)
+
+	for _, pkg := range prog.InitialPackages() {
+		for _, f := range pkg.Files {
+			src, err := r.file(pkg, f, keep)
+			failOn(err)
+			if src == nil {
+				continue
+			}
+
+			name := prog.Fset.File(f.Pos()).Name()
+			fmt.Println(name)
+			if save {
+				failOn(ioutil.WriteFile(name, src, 0666))
+			}
+		}
+	}
+}
+
+type relabeler struct {
+	prog *loader.Program
+	//pkg defines the closed type.
+	pkg *loader.PackageInfo
+	//from is the label renamed or merged.
+	from *types.Const
+	//to is the label merged into, if merging.
+	to *types.Const
+	//name is the new name of the uses of from.
+	name string
+}
+
+func newRelabeler(prog *loader.Program, imp, typ, old, new string, merge bool) (*relabeler, error) {
+	fail := func(format string, args ...interface{}) (*relabeler, error) {
+		return nil, fmt.Errorf(format, args...)
+	}
+
+	pkg := prog.Package(imp)
+	if pkg == nil {
+		return fail("could not load %q", imp)
+	}
+	tn, ok := pkg.Pkg.Scope().Lookup(typ).(*types.TypeName)
+	if !ok {
+		return fail("no type %s in %q", typ, imp)
+	}
+	cts, err := closed.InPackage(prog.Fset, pkg.Files, pkg.Pkg)
+	if err := closedutil.Fatal(err); err != nil {
+		return nil, err
+	}
+
+	var labels [][]*types.Const
+	switch ct := closedutil.Find(tn, cts).(type) {
+	case *closed.Enum:
+		labels = ct.Labels
+	case *closed.Bitset:
+		if merge {
+			return fail("cannot merge the flags of bitset %s", typ)
+		}
+		labels = append(append([][]*types.Const{}, ct.Flags...), ct.OrFlags...)
+	default:
+		return fail("%s is not a closed enum or bitset", typ)
+	}
+
+	r := &relabeler{
+		prog: prog,
+		pkg:  pkg,
+		name: new,
+	}
+	var fromGroup, toGroup int
+	for i, L := range labels {
+		for _, c := range L {
+			switch c.Name() {
+			case old:
+				r.from, fromGroup = c, i
+			case new:
+				r.to, toGroup = c, i
+			}
+		}
+	}
+	if r.from == nil {
+		return fail("%s is not a label of %s", old, typ)
+	}
+
+	if !merge {
+		if !token.IsIdentifier(new) {
+			return fail("%s is not a valid name", new)
+		}
+		if pkg.Pkg.Scope().Lookup(new) != nil {
+			return fail("%s is already declared in %q", new, imp)
+		}
+		return r, nil
+	}
+
+	switch {
+	case r.to == nil:
+		return fail("%s is not a label of %s", new, typ)
+	case fromGroup == toGroup:
+		return fail("%s and %s are already synonyms", old, new)
+	}
+	return r, nil
+}
+
+func (r *relabeler) merging() bool {
+	return r.to != nil
+}
+
+//file renames the uses of r.from in f, a file of pkg,
+//and returns the new source of f or nil if it is unchanged.
+//
+//When merging, a use of r.from in the declaration of another constant
+//is an error, as renaming it would change the value of that constant.
+func (r *relabeler) file(pkg *loader.PackageInfo, f *ast.File, keep bool) ([]byte, error) {
+	info := &pkg.Info
+	changed := false
+
+	var consts []ast.Node
+	for _, d := range f.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.CONST {
+			consts = append(consts, g)
+		}
+	}
+	inConst := func(n ast.Node) bool {
+		for _, g := range consts {
+			if g.Pos() <= n.Pos() && n.End() <= g.End() {
+				return true
+			}
+		}
+		return false
+	}
+
+	qualified := map[*ast.Ident]bool{}
+	generated := pkg == r.pkg && ast.IsGenerated(f)
+	var err error
+	ast.Inspect(f, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if id, ok := n.X.(*ast.Ident); ok {
+				if _, ok := info.Uses[id].(*types.PkgName); ok {
+					qualified[n.Sel] = true
+				}
+			}
+
+		case *ast.Ident:
+			switch {
+			case info.Defs[n] == r.from:
+				n.Name = r.name
+				if r.merging() {
+					n.Name = "_"
+				}
+				changed = true
+
+			case info.Uses[n] == r.from:
+				if r.merging() && inConst(n) {
+					err = fmt.Errorf("%s: %s is used in the declaration of a constant, whose value would change to that of %s", r.prog.Fset.Position(n.Pos()), r.from.Name(), r.name)
+				} else if !qualified[n] {
+					err = r.checkScope(pkg, n)
+				} else if !ast.IsExported(r.name) {
+					err = fmt.Errorf("%s: cannot refer to unexported %s", r.prog.Fset.Position(n.Pos()), r.name)
+				}
+				n.Name = r.name
+				changed = true
+			}
+
+		case *ast.BasicLit:
+			if generated && !r.merging() && n.Kind == token.STRING && n.Value == strconv.Quote(r.from.Name()) {
+				n.Value = strconv.Quote(r.name)
+				changed = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fs := r.prog.Fset
+	if r.merging() {
+		removed, deduped := r.dedupe(info, f)
+		if deduped {
+			changed = true
+		}
+		if len(removed) > 0 {
+			fs = r.withoutLines(f, removed)
+		}
+	}
+
+	if !changed && !(keep && pkg == r.pkg && r.declares(f)) {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fs, f); err != nil {
+		return nil, err
+	}
+	src := buf.Bytes()
+	if keep && pkg == r.pkg && r.declares(f) {
+		src, err = r.keep(src)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return format.Source(src)
+}
+
+//checkScope returns an error if the new name
+//does not refer to the right object at the unqualified use id.
+func (r *relabeler) checkScope(pkg *loader.PackageInfo, id *ast.Ident) error {
+	var want types.Object
+	if r.merging() {
+		want = r.to
+	}
+	s := pkg.Pkg.Scope().Innermost(id.Pos())
+	if s == nil {
+		return nil
+	}
+	if _, obj := s.LookupParent(r.name, id.Pos()); obj != want {
+		return fmt.Errorf("%s: %s would refer to %s", r.prog.Fset.Position(id.Pos()), r.name, obj)
+	}
+	return nil
+}
+
+//declares reports whether f declares the label that the uses of r.from now name.
+func (r *relabeler) declares(f *ast.File) bool {
+	L := r.from
+	if r.merging() {
+		L = r.to
+	}
+	return r.prog.Fset.File(f.Pos()) == r.prog.Fset.File(L.Pos())
+}
+
+//dedupe removes the cases for r.from and r.to after the first from the switches in f,
+//returning the clauses removed entirely and whether any case was removed.
+func (r *relabeler) dedupe(info *types.Info, f *ast.File) (removed []ast.Node, changed bool) {
+	isLabel := func(x ast.Expr) bool {
+		var id *ast.Ident
+		switch x := ast.Unparen(x).(type) {
+		case *ast.Ident:
+			id = x
+		case *ast.SelectorExpr:
+			id = x.Sel
+		default:
+			return false
+		}
+		obj := info.Uses[id]
+		return obj == r.from || obj == r.to
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		sw, ok := n.(*ast.SwitchStmt)
+		if !ok || sw.Tag == nil {
+			return true
+		}
+		seen := false
+		var clauses []ast.Stmt
+		for _, s := range sw.Body.List {
+			cc := s.(*ast.CaseClause)
+			if cc.List == nil {
+				clauses = append(clauses, cc)
+				continue
+			}
+			var list []ast.Expr
+			for _, x := range cc.List {
+				if isLabel(x) {
+					if seen {
+						changed = true
+						continue
+					}
+					seen = true
+				}
+				list = append(list, x)
+			}
+			if len(list) == 0 {
+				if len(cc.Body) > 0 {
+					log.Printf("%s: removed case for %s, which can no longer be taken", r.prog.Fset.Position(cc.Pos()), r.from.Name())
+				}
+				removed = append(removed, cc)
+				continue
+			}
+			cc.List = list
+			clauses = append(clauses, cc)
+		}
+		sw.Body.List = clauses
+		return true
+	})
+
+	return removed, changed
+}
+
+//withoutLines returns a FileSet for printing f without the lines of the nodes removed from it,
+//or any comments in them.
+//
+//The lines are removed from a copy of the file,
+//as the loader's FileSet is shared by every file of the program.
+func (r *relabeler) withoutLines(f *ast.File, removed []ast.Node) *token.FileSet {
+	var cmts []*ast.CommentGroup
+	for _, c := range f.Comments {
+		in := false
+		for _, n := range removed {
+			if n.Pos() <= c.Pos() && c.End() <= n.End() {
+				in = true
+			}
+		}
+		if !in {
+			cmts = append(cmts, c)
+		}
+	}
+	f.Comments = cmts
+
+	tf := r.prog.Fset.File(f.Pos())
+	fs := token.NewFileSet()
+	cp := fs.AddFile(tf.Name(), tf.Base(), tf.Size())
+	cp.SetLines(tf.Lines())
+
+	//last first so the line numbers stay valid
+	for i := len(removed) - 1; i >= 0; i-- {
+		n := removed[i]
+		start, end := cp.Line(n.Pos()), cp.Line(n.End())
+		for j := start; j <= end; j++ {
+			cp.MergeLine(start - 1)
+		}
+	}
+	return fs
+}
+
+//keep adds a deprecated synonym for the old name
+//after the declaration of the label it now names.
+func (r *relabeler) keep(src []byte) ([]byte, error) {
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	target := r.name
+	if r.merging() {
+		target = r.to.Name()
+	}
+	for _, d := range f.Decls {
+		g, ok := d.(*ast.GenDecl)
+		if !ok || g.Tok != token.CONST {
+			continue
+		}
+		for _, s := range g.Specs {
+			for _, id := range s.(*ast.ValueSpec).Names {
+				if id.Name != target {
+					continue
+				}
+				at := fs.Position(g.End()).Offset
+				syn := fmt.Sprintf("\n\n//Deprecated: Use %s instead.\nconst %s = %s\n", target, r.from.Name(), target)
+				var out []byte
+				out = append(out, src[:at]...)
+				out = append(out, syn...)
+				return append(out, src[at:]...), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("could not find the declaration of %s", target)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+//load the packages in testdata.
+func load(t *testing.T) *loader.Program {
+	t.Helper()
+	gopath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GO111MODULE", "off")
+	bc := build.Default
+	bc.GOPATH = gopath
+
+	cfg := &loader.Config{
+		ParserMode: parser.ParseComments,
+		Build:      &bc,
+	}
+	for _, p := range []string{"e", "u", "m"} {
+		cfg.Import(p)
+	}
+	prog, err := cfg.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func TestRelabel(t *testing.T) {
+	tests := []struct {
+		name, old, new string
+		merge, keep    bool
+	}{
+		{"rename", "A", "First", false, false},
+		{"keep", "A", "First", false, true},
+		{"merge", "C", "B", true, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			prog := load(t)
+			lines := map[string]int{}
+			for _, p := range prog.InitialPackages() {
+				for _, f := range p.Files {
+					tf := prog.Fset.File(f.Pos())
+					lines[tf.Name()] = tf.LineCount()
+				}
+			}
+
+			r, err := newRelabeler(prog, "e", "Kind", tc.old, tc.new, tc.merge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			//every changed file, in the style of a txtar archive
+			var got bytes.Buffer
+			for _, imp := range []string{"e", "u"} {
+				pkg := prog.Package(imp)
+				for _, f := range pkg.Files {
+					src, err := r.file(pkg, f, tc.keep)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if src == nil {
+						continue
+					}
+					name := prog.Fset.File(f.Pos()).Name()
+					got.WriteString("-- " + imp + "/" + filepath.Base(name) + " --\n")
+					got.Write(src)
+				}
+			}
+
+			want, err := os.ReadFile(filepath.Join("testdata", tc.name+".golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("got\n%s\nwant\n%s", got.Bytes(), want)
+			}
+
+			for _, p := range prog.InitialPackages() {
+				for _, f := range p.Files {
+					tf := prog.Fset.File(f.Pos())
+					if n := tf.LineCount(); n != lines[tf.Name()] {
+						t.Errorf("%s has %d lines after relabeling, want %d", tf.Name(), n, lines[tf.Name()])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRelabelErrors(t *testing.T) {
+	prog := load(t)
+	tests := []struct {
+		imp, old, new string
+		merge         bool
+		err           string
+	}{
+		{"e", "A", "B", false, "B is already declared"},
+		{"e", "A", "1st", false, "1st is not a valid name"},
+		{"e", "X", "B", true, "X is not a label of Kind"},
+		{"m", "C", "Max", true, "C and Max are already synonyms"},
+	}
+	for _, tc := range tests {
+		_, err := newRelabeler(prog, tc.imp, "Kind", tc.old, tc.new, tc.merge)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s %s into %s: got error %v, want %q", tc.imp, tc.old, tc.new, err, tc.err)
+		}
+	}
+
+	//merging changes the value of Max
+	r, err := newRelabeler(prog, "m", "Kind", "C", "B", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := prog.Package("m")
+	_, err = r.file(pkg, pkg.Files[0], false)
+	if want := "C is used in the declaration of a constant"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
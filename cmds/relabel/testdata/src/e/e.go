@@ -0,0 +1,10 @@
+package e
+
+type Kind int
+
+const (
+	A Kind = iota
+	B
+	C
+	D
+)
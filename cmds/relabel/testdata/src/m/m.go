@@ -0,0 +1,11 @@
+package m
+
+type Kind int
+
+const (
+	A Kind = iota
+	B
+	C
+)
+
+const Max = C
@@ -0,0 +1,27 @@
+package u
+
+import "e"
+
+func describe(k e.Kind) string {
+	switch k {
+	case e.A:
+		return "first"
+	case e.B:
+		return "second"
+	case e.C:
+		//unreachable after merging C into B
+		return "third"
+	}
+	return "last"
+}
+
+func late(k e.Kind) bool {
+	switch k {
+	case e.B:
+	case e.C, e.D:
+		return true
+	}
+	return false
+}
+
+var all = []e.Kind{e.A, e.B, e.C, e.D}
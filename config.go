@@ -0,0 +1,143 @@
+package closed
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+//A Kind is a set of the kinds of closed type.
+type Kind uint
+
+const (
+	//KindEnum is the kind of an *Enum.
+	KindEnum Kind = 1 << iota
+	//KindBitset is the kind of a *Bitset.
+	KindBitset
+	//KindInterface is the kind of an *Interface.
+	KindInterface
+	//KindEmptySum is the kind of an *EmptySum.
+	KindEmptySum
+	//KindOptionalStruct is the kind of an *OptionalStruct.
+	KindOptionalStruct
+
+	//AllKinds is the set of every kind of closed type.
+	AllKinds = KindEnum | KindBitset | KindInterface | KindEmptySum | KindOptionalStruct
+)
+
+var kindNames = map[Kind]string{
+	KindEnum:           "enum",
+	KindBitset:         "bitset",
+	KindInterface:      "interface",
+	KindEmptySum:       "empty sum",
+	KindOptionalStruct: "optional struct",
+}
+
+func (k Kind) String() string {
+	if s, ok := kindNames[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("Kind(%d)", uint(k))
+}
+
+//KindOf returns the Kind of t, which must be one of the closed types of this package.
+func KindOf(t Type) Kind {
+	switch t.(type) {
+	case *Enum:
+		return KindEnum
+	case *Bitset:
+		return KindBitset
+	case *Interface:
+		return KindInterface
+	case *EmptySum:
+		return KindEmptySum
+	case *OptionalStruct:
+		return KindOptionalStruct
+	}
+	panic("unknown closed type")
+}
+
+//A Config tunes the heuristics used to recognize closed types.
+//
+//The zero Config, which is also used when a *Config is nil,
+//is the configuration used by InPackage.
+type Config struct {
+	//MinEnumConstants is the fewest constants an integer enum may have.
+	//An integer type with fewer is assumed to be for sentinels.
+	//If zero, it is 2.
+	//
+	//Only types with constants are considered,
+	//so set it to 1 to accept every integer type with constants.
+	MinEnumConstants int
+
+	//MinFlags is the fewest single bit values a bitset may have.
+	//If zero, it is 2.
+	//
+	//A bitset must have at least 1 flag.
+	MinFlags int
+
+	//FlagsPerMultibit limits a bitset to one multibit value
+	//for every FlagsPerMultibit single bit values.
+	//If zero, it is 2.
+	//If negative, there is no limit.
+	FlagsPerMultibit int
+
+	//Kinds of closed type to recognize.
+	//If zero, it is AllKinds.
+	Kinds Kind
+
+	//NoSpecialCases disables recognizing the closed types
+	//in the standard library that the heuristics miss:
+	//the Null types of database/sql
+	//and the Token types of encoding/json and encoding/xml.
+	NoSpecialCases bool
+
+	//Session, if not nil, remembers the method sets computed by InPackage and Explain
+	//across calls, as the Session would, while c tunes the heuristics.
+	//The Config of the Session is not used.
+	Session *Session
+}
+
+//withDefaults returns a copy of c with any zero thresholds set to their defaults.
+func (c *Config) withDefaults() Config {
+	var d Config
+	if c != nil {
+		d = *c
+	}
+	if d.MinEnumConstants == 0 {
+		d.MinEnumConstants = 2
+	}
+	if d.MinFlags == 0 {
+		d.MinFlags = 2
+	}
+	if d.FlagsPerMultibit == 0 {
+		d.FlagsPerMultibit = 2
+	}
+	if d.Kinds == 0 {
+		d.Kinds = AllKinds
+	}
+	return d
+}
+
+//InPackage is InPackage using the heuristics as tuned by c.
+func (c *Config) InPackage(fs *token.FileSet, files []*ast.File, pkg *types.Package) ([]Type, error) {
+	return c.session().InPackage(fs, files, pkg)
+}
+
+//Explain is Explain using the heuristics as tuned by c.
+func (c *Config) Explain(fs *token.FileSet, files []*ast.File, pkg *types.Package) ([]Type, []Explanation, error) {
+	return c.session().Explain(fs, files, pkg)
+}
+
+func (c *Config) session() *Session {
+	if c == nil || c.Session == nil {
+		s := NewSession(0)
+		s.Config = c
+		return s
+	}
+	return &Session{
+		Config:     c,
+		methodSets: c.Session.methodSets,
+	}
+}
@@ -0,0 +1,137 @@
+package closed
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	const src = `package p
+
+type One int
+
+const O One = 1
+
+type Two int
+
+const (
+	T0 Two = iota
+	T1
+)
+
+type Flags uint
+
+const (
+	F0 Flags = 1 << iota
+	F1
+	F2
+	F12 = F1 | F2
+	F01 = F0 | F1
+)
+
+type Sum interface {
+	isSum()
+}
+
+type A struct{}
+
+func (A) isSum() {}
+`
+	l := check(t, src)
+
+	for _, c := range []struct {
+		name string
+		cfg  *Config
+		want map[string]int
+	}{
+		{
+			name: "nil",
+			want: map[string]int{"Two": 2, "Sum": 1},
+		},
+		{
+			name: "one label enums",
+			cfg:  &Config{MinEnumConstants: 1},
+			want: map[string]int{"One": 1, "Two": 2, "Sum": 1},
+		},
+		{
+			name: "three label enums",
+			cfg:  &Config{MinEnumConstants: 3},
+			want: map[string]int{"Sum": 1},
+		},
+		{
+			name: "multibit",
+			cfg:  &Config{FlagsPerMultibit: 1},
+			want: map[string]int{"Two": 2, "Flags": 3, "Sum": 1},
+		},
+		{
+			name: "unlimited multibit",
+			cfg:  &Config{FlagsPerMultibit: -1},
+			want: map[string]int{"Two": 2, "Flags": 3, "Sum": 1},
+		},
+		{
+			name: "too few flags",
+			cfg:  &Config{FlagsPerMultibit: -1, MinFlags: 4},
+			want: map[string]int{"Two": 2, "Flags": 5, "Sum": 1},
+		},
+		{
+			name: "kinds",
+			cfg:  &Config{FlagsPerMultibit: -1, Kinds: KindBitset | KindInterface},
+			want: map[string]int{"Flags": 3, "Sum": 1},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ts, err := c.cfg.InPackage(l.fs, l.files, l.pkg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(ts); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestConfigSpecialCases(t *testing.T) {
+	for _, l := range loadStd(t) {
+		switch l.pkg.Path() {
+		case "database/sql", "encoding/json", "encoding/xml":
+		default:
+			continue
+		}
+		ts, err := InPackage(l.fs, l.files, l.pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := &Config{NoSpecialCases: true}
+		off, err := cfg.InPackage(l.fs, l.files, l.pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(off) >= len(ts) {
+			t.Errorf("%s: got %d closed types without special cases, want fewer than %d", l.pkg.Path(), len(off), len(ts))
+		}
+		for _, ct := range off {
+			switch ct.(type) {
+			case *OptionalStruct, *EmptySum:
+				t.Errorf("%s: got special case %s", l.pkg.Path(), ct.Types()[0].Name())
+			}
+		}
+	}
+}
+
+func TestConfigSession(t *testing.T) {
+	l := check(t, "package p\n\ntype Sum interface {\n\tisSum()\n}\n\ntype A struct{}\n\nfunc (A) isSum() {}\n")
+	s := NewSession(0)
+	cfg := &Config{MinEnumConstants: 1, Session: s}
+	for i := 0; i < 2; i++ {
+		if _, err := cfg.InPackage(l.fs, l.files, l.pkg); err != nil {
+			t.Fatal(err)
+		}
+		if s.methodSets.len() == 0 {
+			t.Fatal("method sets not remembered by the session")
+		}
+	}
+	if s.Config != nil {
+		t.Error("session config changed")
+	}
+}
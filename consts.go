@@ -10,77 +10,79 @@ import (
 	"sort"
 )
 
-func grabEnums(fs *token.FileSet, decls map[string]*ast.ValueSpec, consts []*types.Const) (enums, bitsets map[*types.TypeName][]*constants, err error) {
-	intvec, bools, restvec := binConstants(filterConstants(consts))
+func grabEnums(why *explainer, cfg *Config, decls map[token.Pos]declPattern, consts []*types.Const) (enums, bitsets map[*types.TypeName][]*constants) {
+	intvec, bools, restvec := binConstants(filterConstants(why, consts))
 
 	enums = map[*types.TypeName][]*constants{}
 
 	//bool constants are always enums, but have to be treated separately as the rest
 	//depends on < being defined on the type
 	for t, cs := range groupConstants(bools) {
+		why.why(t, "bool enum with %d constants", len(cs))
 		enums[t] = groupBool(cs)
 	}
 
 	//nonintegral types are always enums, by our definition
 	for t, cs := range groupConstants(restvec) {
+		why.why(t, "enum of %s with %d constants", t.Type().Underlying(), len(cs))
 		enums[t] = groupLabels(cs)
 	}
 
-	ints, intbits, err := integralConsts(fs, decls, intvec)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	for t, cs := range ints {
-		//int enum with one member usually a sentinel
-		if len(cs) > 1 {
-			enums[t] = groupLabels(cs)
-		}
-	}
-	ints = nil
-
+	//integral types are bitsets if declared with bit operations, as in 1 << iota,
+	//and enums if declared with iota alone.
+	//Otherwise, they are bitsets if their values look like flags.
 	bitsets = map[*types.TypeName][]*constants{}
-	for t, cs := range intbits {
+	for t, cs := range groupConstants(intvec) {
 		labels := groupLabels(cs)
-		if len(labels) == 1 || !hasBitsetValues(labels) {
-			continue
-		}
-		bitsets[t] = labels
-	}
-
-	return enums, bitsets, nil
-}
-
-func integralConsts(fs *token.FileSet, decls map[string]*ast.ValueSpec, consts []*types.Const) (enums, bitsets map[*types.TypeName][]*types.Const, err error) {
-	specs, err := specsOfConsts(decls, consts)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	groups := groupConstants(consts)
-	bitsets = map[*types.TypeName][]*types.Const{}
-	for t, cs := range groups {
-		ok, err := allValidExprs(fs, specs, cs)
-		if err != nil {
-			return nil, nil, err
+		var bitset, flaglike bool
+		var reason string
+		pattern := patternOf(decls, cs)
+		switch pattern {
+		case bitPattern:
+			bitset, flaglike, reason = hasBitsetValues(cfg, labels, true)
+		case iotaPattern:
+			reason = "they are declared with iota and no bit operations"
+		default:
+			bitset, flaglike, reason = hasBitsetValues(cfg, labels, false)
 		}
-		if !ok {
-			bitsets[t] = cs
-			delete(groups, t)
+		switch {
+		case bitset && pattern == bitPattern:
+			why.why(t, "bitset with %d values declared with bit operations", len(labels))
+			bitsets[t] = labels
+		case bitset:
+			why.why(t, "bitset with %d values that are flags or combinations of flags", len(labels))
+			bitsets[t] = labels
+		case flaglike:
+			why.why(t, "the values look like flags, but are not a bitset as %s", reason)
+		case len(cs) >= cfg.MinEnumConstants:
+			why.why(t, "integer enum with %d constants, not a bitset as %s", len(cs), reason)
+			enums[t] = labels
+		case len(cs) == 1:
+			//int enum with one member usually a sentinel
+			why.why(t, "single constant %s treated as a sentinel, not an enum", cs[0].Name())
+		default:
+			why.why(t, "%d constants, fewer than %d, treated as sentinels, not an enum", len(cs), cfg.MinEnumConstants)
 		}
 	}
 
-	return groups, bitsets, nil
+	return enums, bitsets
 }
 
-//filerConstants that are of a named type defined in the same package.
-func filterConstants(consts []*types.Const) []*types.Const {
+//filterConstants that are of a named type, or an alias of one, defined in the same package.
+//
+//The constants of an instance of a generic type are not labels of that type,
+//as no other instance has them.
+func filterConstants(why *explainer, consts []*types.Const) []*types.Const {
 	var acc []*types.Const
 	for _, c := range consts {
-		nm, ok := c.Type().(*types.Named)
+		nm, ok := types.Unalias(c.Type()).(*types.Named)
 		if !ok || c.Pkg() != nm.Obj().Pkg() {
 			continue
 		}
+		if nm.TypeArgs().Len() > 0 {
+			why.why(nm.Obj(), "constants of an instance of a generic type, like %s, do not make an enum", c.Name())
+			continue
+		}
 		acc = append(acc, c)
 	}
 	return acc
@@ -106,7 +108,7 @@ func binConstants(consts []*types.Const) (ints, bools, rest []*types.Const) {
 func groupConstants(consts []*types.Const) map[*types.TypeName][]*types.Const {
 	m := map[*types.TypeName][]*types.Const{}
 	for _, c := range consts {
-		t := c.Type().(*types.Named).Obj()
+		t := types.Unalias(c.Type()).(*types.Named).Obj()
 		m[t] = append(m[t], c)
 	}
 	return m
@@ -134,10 +136,14 @@ func groupBool(consts []*types.Const) []*constants {
 		}
 	}
 
-	return []*constants{
-		{val: F, labels: f},
-		{val: T, labels: t},
+	var acc []*constants
+	if len(f) > 0 {
+		acc = append(acc, &constants{val: F, labels: f})
 	}
+	if len(t) > 0 {
+		acc = append(acc, &constants{val: T, labels: t})
+	}
+	return acc
 }
 
 //groupLabels of a vector of constants of homogeneous type.
@@ -147,8 +153,8 @@ func groupLabels(consts []*types.Const) []*constants {
 	}
 
 	//sort incoming so all equal labels are in a row
-	sort.Slice(consts, func(i, j int) bool {
-		return constant.Compare(consts[i].Val(), token.LEQ, consts[j].Val())
+	sort.SliceStable(consts, func(i, j int) bool {
+		return constant.Compare(consts[i].Val(), token.LSS, consts[j].Val())
 	})
 
 	var acc []*constants
@@ -172,107 +178,94 @@ func groupLabels(consts []*types.Const) []*constants {
 	return acc
 }
 
-//specsOfConsts associates typed constants with their ast spec.
-func specsOfConsts(nms map[string]*ast.ValueSpec, consts []*types.Const) (map[*types.Const]*ast.ValueSpec, error) {
-	m := map[*types.Const]*ast.ValueSpec{}
-	for _, c := range consts {
-		var ok bool
-		nm := c.Name()
-		m[c], ok = nms[nm]
-		if !ok {
-			return nil, fmt.Errorf("could not find ValueSpec for %q", nm)
-		}
-	}
-	return m, nil
-}
+//A declPattern is what the declaration of a constant says about its type.
+type declPattern int
 
-//TODO really need to replace this with finding a decl like 1 << iota
+const (
+	//noPattern says nothing, as with a literal.
+	noPattern declPattern = iota
+	//iotaPattern uses iota without bit operations, as in an enum.
+	iotaPattern
+	//bitPattern uses a shift or an or, as in 1 << iota or A | B.
+	bitPattern
+)
 
-//allValidExprs uses spec to determine if consts (all of one type) only contains legal enum expressions.
-func allValidExprs(fs *token.FileSet, spec map[*types.Const]*ast.ValueSpec, consts []*types.Const) (ok bool, err error) {
-	defer func() {
-		if x := recover(); x != nil {
-			if expr, ok := x.(ast.Node); ok {
-				p := fs.Position(expr.Pos())
-				err = fmt.Errorf("%s: unexpected %T in const definition", p, expr)
-			} else {
-				panic(x)
-			}
-		}
-	}()
-	for _, c := range consts {
-		s := spec[c]
-		//part of an iota, so we only care about the iota line
-		if len(s.Values) == 0 {
-			continue
+//constPatterns adds the pattern of each constant declared in d to m.
+//
+//A spec without values repeats the expressions of the last spec with them.
+func constPatterns(m map[token.Pos]declPattern, d *ast.GenDecl) {
+	var last []ast.Expr
+	for _, spec := range d.Specs {
+		vs := spec.(*ast.ValueSpec)
+		if len(vs.Values) > 0 {
+			last = vs.Values
 		}
-
-		//find expression for constant
-		var expr ast.Expr
-		for i, ident := range s.Names {
-			if ident.Name == c.Name() {
-				expr = s.Values[i]
-				break
+		for i, nm := range vs.Names {
+			if i < len(last) {
+				m[nm.Pos()] = exprPattern(last[i])
 			}
 		}
-
-		if !validExpr(expr) {
-			return false, nil
-		}
 	}
-
-	return true, nil
 }
 
-func validExpr(x ast.Expr) bool {
-	switch x := x.(type) {
-	default:
-		panic(x)
-	case *ast.Ident: // A = B
-		return true
-	case *ast.BasicLit: // A = 5
-		return true
-	case *ast.ParenExpr:
-		return validExpr(x.X)
-	case *ast.SelectorExpr: //A = pkg.A
-		return true
-	case *ast.CallExpr: // typeConversion(x)
-		return validExpr(x.Args[0])
-	case *ast.UnaryExpr:
-		return validExpr(x.X)
-	case *ast.BinaryExpr:
-		//while there are other bitwise operators these are the ones
-		//that always make it a bitset
-		if tokenIn(x.Op, token.SHR, token.SHL, token.OR) {
-			return false
+//exprPattern of x, the expression declaring a constant.
+func exprPattern(x ast.Expr) declPattern {
+	p := noPattern
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			switch n.Op {
+			case token.SHL, token.SHR, token.OR:
+				p = bitPattern
+			}
+		case *ast.Ident:
+			if n.Name == "iota" && p == noPattern {
+				p = iotaPattern
+			}
 		}
-		return validExpr(x.X) && validExpr(x.Y)
-	}
+		return p != bitPattern
+	})
+	return p
 }
 
-func allPositive(cs []*types.Const) bool {
-	z := constant.MakeInt64(0)
-	for _, c := range cs {
-		if constant.Compare(c.Val(), token.LSS, z) {
-			return false
+//patternOf consts, all of one type:
+//bitPattern if any is declared with bit operations,
+//otherwise iotaPattern if any is declared with iota.
+func patternOf(decls map[token.Pos]declPattern, consts []*types.Const) declPattern {
+	p := noPattern
+	for _, c := range consts {
+		if q := decls[c.Pos()]; q > p {
+			p = q
 		}
 	}
-	return true
+	return p
 }
 
-func hasBitsetValues(lbls []*constants) bool {
-	vals := make([]constant.Value, len(lbls))
-	for i, lbl := range lbls {
-		vals[i] = lbl.val
+//hasBitsetValues reports whether the values of lbls, sorted by value, are a bitset.
+//If not, the reason is also returned,
+//and whether the values are still too like flags to be an enum.
+//
+//The values are a bitset when they are powers of two
+//and a few combinations of them.
+//Unless declared with bit operations, as by 1 << iota,
+//values without gaps, like 1 and 2 or 0 through 4,
+//are indistinguishable from an iota enum and so are treated as one.
+//Values that are mostly, and at least three, powers of two,
+//but have too many or bad combinations, are neither.
+func hasBitsetValues(cfg *Config, lbls []*constants, declared bool) (bitset, flaglike bool, reason string) {
+	if len(lbls) < 2 {
+		return false, false, "there is only one value"
 	}
 
 	//partition into multibit values and tracking info for unibit values
 	var all uint64
 	unibit := 0
-	multibit := make([]uint64, 0, len(vals))
-	for _, v := range vals {
-		//we're also checking signed ints, but they're always positive
-		u, _ := constant.Uint64Val(v)
+	multibit := make([]uint64, 0, len(lbls))
+	for _, lbl := range lbls {
+		u, exact := constant.Uint64Val(lbl.val)
+		if !exact {
+			return false, false, fmt.Sprintf("value %s does not fit in a uint64", lbl.val)
+		}
 		if u == 0 {
 			continue
 		}
@@ -285,34 +278,54 @@ func hasBitsetValues(lbls []*constants) bool {
 		}
 	}
 
+	if !declared && contiguous(lbls) {
+		return false, false, "the values are contiguous"
+	}
+
 	//too few unibit values to make decision confidently
-	if unibit < 2 {
-		return false
+	if unibit < cfg.MinFlags {
+		return false, false, fmt.Sprintf("only %d single bit values", unibit)
 	}
 
-	if len(multibit) > unibit/2 {
-		return false
+	//more than a couple powers of two, outnumbering the rest, are unlikely by chance
+	flaglike = unibit > 2 && unibit > len(multibit)
+
+	if n := cfg.FlagsPerMultibit; n > 0 && len(multibit) > unibit/n {
+		return false, flaglike, fmt.Sprintf("%d multibit values is more than one for every %d of the %d single bit values", len(multibit), n, unibit)
 	}
 
 	for _, u := range multibit {
 		//reject if multibit value has bits not in any single bit value
 		if u&^all != 0 {
-			return false
+			return false, flaglike, fmt.Sprintf("multibit value %#x has bits not in any single bit value", u)
 		}
 	}
 
+	return true, false, ""
+}
+
+//contiguous reports whether the values of lbls, sorted by value, have no gaps.
+func contiguous(lbls []*constants) bool {
+	one := constant.MakeInt64(1)
+	for i := 1; i < len(lbls); i++ {
+		next := constant.BinaryOp(lbls[i-1].val, token.ADD, one)
+		if constant.Compare(next, token.NEQ, lbls[i].val) {
+			return false
+		}
+	}
 	return true
 }
 
 func sortLabels(fs *token.FileSet, labels []*types.Const) {
 	sort.Slice(labels, func(i, j int) bool {
-		a, b := labels[i], labels[j]
-		//if they're defined in different files, first sort by file name
-		if fs.File(a.Pos()).Name() < fs.File(b.Pos()).Name() {
-			return true
-		}
-		//otherwise rely on monotinicity of positions
-		return a.Pos() < b.Pos()
+		return declaredBefore(fs, labels[i].Pos(), labels[j].Pos())
+	})
+}
+
+//sortGroups of labels, each already sorted, by their first declaration.
+func sortGroups(fs *token.FileSet, groups [][]*types.Const) {
+	sort.Slice(groups, func(i, j int) bool {
+		return declaredBefore(fs, groups[i][0].Pos(), groups[j][0].Pos())
 	})
 }
 
@@ -326,6 +339,7 @@ func pkgEnums(fs *token.FileSet, aliases map[string][]*types.TypeName, enums map
 			sortLabels(fs, c.labels)
 			lbls[i] = c.labels
 		}
+		sortGroups(fs, lbls)
 
 		acc = append(acc, &Enum{
 			typs:   names,
@@ -351,6 +365,8 @@ func pkgBitsets(fs *token.FileSet, aliases map[string][]*types.TypeName, bitsets
 				multibit = append(multibit, c.labels)
 			}
 		}
+		sortGroups(fs, flag)
+		sortGroups(fs, multibit)
 
 		acc = append(acc, &Bitset{
 			typs:    names,
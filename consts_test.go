@@ -0,0 +1,162 @@
+package closed
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+//kindOf the closed type in ts defined by T in pkg, or that T is an alias of:
+//enum, bitset, or empty if it is not either.
+func kindOf(pkg *types.Package, ts []Type) string {
+	T := pkg.Scope().Lookup("T").(*types.TypeName)
+	if n, ok := types.Unalias(T.Type()).(*types.Named); ok {
+		T = n.Obj()
+	}
+	for _, t := range ts {
+		if t.Types()[0] != T {
+			continue
+		}
+		switch t.(type) {
+		case *Enum:
+			return "enum"
+		case *Bitset:
+			return "bitset"
+		}
+	}
+	return ""
+}
+
+func TestConstExprs(t *testing.T) {
+	for _, c := range []struct {
+		name, src, want string
+	}{
+		{"iota", "type T int\nconst (\n\tA T = iota\n\tB\n\tC\n)", "enum"},
+		{"literals", "type T int\nconst (\n\tA T = 1\n\tB T = 2\n)", "enum"},
+		{"rune literals", "type T rune\nconst (\n\tA T = 'a'\n\tB T = 'b'\n)", "enum"},
+		{"untyped", "type T int\nconst (\n\tA, B T = 0, 1\n)", "enum"},
+		{"synonyms", "type T int\nconst (\n\tA T = iota\n\tB\n\tC = B\n)", "enum"},
+		{"qualified", "type T int\nconst (\n\tA T = math.MaxInt8\n\tB T = math.MinInt8\n)", "enum"},
+		{"paren", "type T int\nconst (\n\tA T = (iota)\n\tB\n)", "enum"},
+		{"conversion", "type T int\nconst (\n\tA = T(0)\n\tB = T(1)\n)", "enum"},
+		{"alias", "type E int\ntype T = E\nconst (\n\tA T = 0\n\tB T = 1\n)", "enum"},
+		{"generic conversion", "type G[P any] int\ntype T = G[int]\nconst (\n\tA = T(0)\n\tB = G[int](1)\n\tC = G[int](2)\n)", ""},
+		{"unary", "type T int\nconst (\n\tA T = -1\n\tB T = +1\n\tC T = ^0\n)", "enum"},
+		{"arithmetic", "type T int\nconst (\n\tA T = iota*10 + 1\n\tB\n\tC T = 100 / 3 % 7\n\tD T = 1 - 2\n)", "enum"},
+		{"and xor", "type T int\nconst (\n\tA T = 3 & 1\n\tB T = 3 ^ 1\n\tC T = 7 &^ 4\n)", "enum"},
+		{"len cap", "type T int\nconst (\n\tA = T(len([1]int{}))\n\tB = T(cap([2]int{}))\n\tC = T(len(\"abc\"))\n)", "enum"},
+		{"unsafe", "type T uintptr\nvar s struct{ a, b int64 }\nconst (\n\tA = T(unsafe.Sizeof(int32(0)))\n\tB = T(unsafe.Alignof(*(*int16)(nil)))\n\tC = T(unsafe.Offsetof(s.b))\n)", "enum"},
+		{"min max", "type T int\nconst (\n\tA T = min(1, 2)\n\tB T = max(3, 2)\n)", "enum"},
+		{"complex", "type T float64\nconst (\n\tA T = real(1 + 2i)\n\tB T = imag(complex(1, 3))\n)", "enum"},
+		{"strings", "type T string\nconst (\n\tA T = \"a\" + \"b\"\n\tB T = `c`\n)", "enum"},
+		{"comparison", "type T bool\nconst (\n\tA T = 1 < 2\n\tB T = !A\n)", "enum"},
+		{"sentinel", "type T int\nconst A T = 1", ""},
+		{"shift iota", "type T uint\nconst (\n\tA T = 1 << iota\n\tB\n\tC\n)", "bitset"},
+		{"shift iota offset", "type T uint\nconst (\n\t_ = iota\n\tA T = 1 << iota\n\tB\n\tC\n)", "bitset"},
+		{"shift right", "type T uint8\nconst (\n\tA T = 8 >> iota\n\tB\n\tC\n\tD\n)", "bitset"},
+		{"or", "type T int\nconst (\n\tA T = 1\n\tB T = 2\n\tC T = 4\n\tD T = 8\n\tAB = A | B\n)", "bitset"},
+		{"or contiguous", "type T int\nconst (\n\tA T = 1\n\tB T = 2\n\tC T = 4\n\tAB = A | B\n)", "bitset"},
+		{"two flags", "type T uint\nconst (\n\tA T = 1 << iota\n\tB\n)", "bitset"},
+		{"iota skip", "type T int\nconst (\n\tA T = iota\n\tB\n\tC\n\t_\n\tD\n\tE\n)", "enum"},
+		{"iota then literal", "type T int\nconst (\n\tA T = iota\n\tB\n\tC\n\tD T = 8\n)", "enum"},
+		{"flag literals", "type T uint\nconst (\n\tA T = 1\n\tB T = 2\n\tC T = 4\n)", "bitset"},
+		{"negative", "type T int\nconst (\n\tA T = -1\n\tB T = 2\n\tC T = 4\n)", "enum"},
+		{"shift conversion", "type T int\nconst (\n\tA = T(1 << iota)\n\tB\n\tC\n)", "bitset"},
+		{"shift not bits", "type T int\nconst (\n\tA T = 1<<iota - 1\n\tB\n\tC\n\tD\n)", "enum"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fs := token.NewFileSet()
+			src := "package p\n\nimport (\n\t\"math\"\n\t\"unsafe\"\n)\n\nvar _ = math.Pi\nvar _ unsafe.Pointer\n\n" + c.src + "\n"
+			f, err := parser.ParseFile(fs, "p.go", src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := []*ast.File{f}
+			cfg := types.Config{
+				Importer: importer.Default(),
+			}
+			pkg, err := cfg.Check("p", fs, files, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts, err := InPackage(fs, files, pkg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := kindOf(pkg, ts); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLabelOrder(t *testing.T) {
+	const src = `package p
+
+type T int
+
+const (
+	B T = 2
+	A T = 1
+	Z T = 0
+	C   = A
+)
+
+type F uint
+
+const (
+	F4 F = 1 << 2
+	F1 F = 1 << 0
+	F2 F = 1 << 1
+)
+
+type Yes bool
+
+const Y Yes = true
+`
+	l := check(t, src)
+	ts, err := InPackage(l.fs, l.files, l.pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(Ls [][]*types.Const) [][]string {
+		var acc [][]string
+		for _, L := range Ls {
+			var nms []string
+			for _, c := range L {
+				nms = append(nms, c.Name())
+			}
+			acc = append(acc, nms)
+		}
+		return acc
+	}
+	want := map[string][][]string{
+		"T":   {{"B"}, {"A", "C"}, {"Z"}},
+		"F":   {{"F4"}, {"F1"}, {"F2"}},
+		"Yes": {{"Y"}},
+	}
+	for _, ct := range ts {
+		nm := ct.Types()[0].Name()
+		var got [][]string
+		switch ct := ct.(type) {
+		case *Enum:
+			got = names(ct.Labels)
+		case *Bitset:
+			got = names(ct.Flags)
+		}
+		if _, bitset := ct.(*Bitset); bitset != (nm == "F") {
+			t.Errorf("%s: got %T", nm, ct)
+		}
+		if !reflect.DeepEqual(got, want[nm]) {
+			t.Errorf("%s: got %v, want %v", nm, got, want[nm])
+		}
+		delete(want, nm)
+	}
+	if len(want) > 0 {
+		t.Errorf("missing %v", want)
+	}
+}
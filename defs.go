@@ -22,11 +22,20 @@ type Enum struct {
 	isType
 	typs []*types.TypeName
 	//NonZero is true if there is an explicit comment directive forbidding
-	//zero from being a valid value.
+	//zero from being a valid value:
+	//	//closed:nonzero
+	//in the doc comment of the type.
 	//It is meaningless if there is a label for the zero value.
 	NonZero bool
-	//Labels are the valid members of the enumeration.
-	//If len(Labels[i]) > 1, then Labels[i][1:] are synonyms.
+	//Ordered is true if there is a comment directive declaring
+	//that the order of the labels is meaningful:
+	//	//closed:ordered
+	//in the doc comment of the type.
+	Ordered bool
+	//Labels are the valid members of the enumeration,
+	//in order of the declaration of Labels[i][0].
+	//If len(Labels[i]) > 1, then Labels[i][1:] are synonyms,
+	//in order of declaration.
 	//For example, given
 	//	type Enum int
 	//	const (
@@ -48,8 +57,10 @@ type Bitset struct {
 	isType
 	typs []*types.TypeName
 	//Flags are the single bit labels in this Bitset.
+	//They are grouped and ordered as the Labels of an Enum.
 	Flags [][]*types.Const
 	//OrFlags are any multibit convienence labels.
+	//They are grouped and ordered as the Labels of an Enum.
 	OrFlags [][]*types.Const
 }
 
@@ -77,7 +88,9 @@ type Interface struct {
 	isType
 	typs []*types.TypeName
 	//NonNil is true if there is a comment directive marked this
-	//type as not being able to contain nil.
+	//type as not being able to contain nil:
+	//	//closed:nonnil
+	//in the doc comment of the type.
 	NonNil bool
 	//Members are the types in the sum.
 	Members []*TypeNamesAndType
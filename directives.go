@@ -0,0 +1,85 @@
+package closed
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+//Comment directives that further restrict a closed type.
+//A directive must be on a line of its own in the doc comment of the type,
+//with no space after the //, as in
+//	//closed:nonzero
+//	type Enum int
+const (
+	//directiveNonZero marks an Enum whose zero value is not legal.
+	directiveNonZero = "closed:nonzero"
+	//directiveNonNil marks an Interface that may not contain nil.
+	directiveNonNil = "closed:nonnil"
+	//directiveOrdered marks an Enum whose labels may be compared by order.
+	directiveOrdered = "closed:ordered"
+)
+
+//directivesOf collects the directives in the doc comments of each type declared in files.
+func directivesOf(files []*ast.File) map[string][]string {
+	m := map[string][]string{}
+	for _, f := range files {
+		for _, d := range f.Decls {
+			g, ok := d.(*ast.GenDecl)
+			if !ok || g.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range g.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				//a lone type spec's comment is attached to the decl
+				if doc == nil && len(g.Specs) == 1 {
+					doc = g.Doc
+				}
+				if ds := directives(doc); len(ds) > 0 {
+					m[ts.Name.Name] = ds
+				}
+			}
+		}
+	}
+	return m
+}
+
+func directives(doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	var acc []string
+	for _, c := range doc.List {
+		if strings.HasPrefix(c.Text, "//closed:") {
+			acc = append(acc, strings.TrimSpace(c.Text[2:]))
+		}
+	}
+	return acc
+}
+
+func hasDirective(ds []string, d string) bool {
+	for _, x := range ds {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+//applyDirectives to the types in ts.
+func applyDirectives(dirs map[string][]string, ts []Type) {
+	for _, t := range ts {
+		ds := dirs[t.Types()[0].Name()]
+		if len(ds) == 0 {
+			continue
+		}
+		switch t := t.(type) {
+		case *Enum:
+			t.NonZero = hasDirective(ds, directiveNonZero)
+			t.Ordered = hasDirective(ds, directiveOrdered)
+		case *Interface:
+			t.NonNil = hasDirective(ds, directiveNonNil)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package closed
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+//Category classifies an Error.
+type Category int
+
+const (
+	//BadDecl is a declaration that could not be parsed.
+	BadDecl Category = iota
+)
+
+var categoryNames = [...]string{
+	BadDecl: "bad declaration",
+}
+
+func (c Category) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return fmt.Sprintf("Category(%d)", c)
+	}
+	return categoryNames[c]
+}
+
+//An Error is a problem that prevented InPackage
+//from considering a declaration.
+type Error struct {
+	//Pos is the position of the problem, if known.
+	Pos      token.Position
+	Category Category
+	//Object is the object that could not be considered,
+	//or nil if the problem is not with a single object.
+	Object types.Object
+	Msg    string
+}
+
+func (e Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+//Errors are all the problems found by InPackage.
+//
+//When InPackage returns Errors,
+//it also returns all the closed types it could find regardless.
+type Errors []Error
+
+func (es Errors) Error() string {
+	switch len(es) {
+	case 0:
+		return "no errors"
+	case 1:
+		return es[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", es[0], len(es)-1)
+}
+
+//add an Error at pos, which may be token.NoPos.
+func (es *Errors) add(fs *token.FileSet, pos token.Pos, cat Category, obj types.Object, format string, args ...interface{}) {
+	*es = append(*es, Error{
+		Pos:      fs.Position(pos),
+		Category: cat,
+		Object:   obj,
+		Msg:      fmt.Sprintf(format, args...),
+	})
+}
+
+//err returns es sorted by position or nil if there are no errors.
+func (es Errors) err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	sort.SliceStable(es, func(i, j int) bool {
+		a, b := es[i].Pos, es[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	return es
+}
@@ -0,0 +1,114 @@
+package closed
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	const good = `package p
+
+type A int
+
+const (
+	A0 A = iota
+	A1
+)
+`
+	const other = `package p
+
+type B int
+
+const (
+	B0 B = B(len([2]int{}))
+	B1 B = 3
+)
+
+type C int
+
+const (
+	C0 C = iota
+	C1
+)
+`
+	const decl = `package p
+
+1 + 1
+`
+
+	fs := token.NewFileSet()
+	var files []*ast.File
+	for _, file := range []struct{ name, src string }{
+		{"good.go", good},
+		{"other.go", other},
+		{"decl.go", decl},
+	} {
+		//decl.go has errors, but keep the partial AST
+		f, _ := parser.ParseFile(fs, file.name, file.src, parser.ParseComments)
+		files = append(files, f)
+	}
+	cfg := types.Config{
+		Error: func(error) {},
+	}
+	pkg, _ := cfg.Check("p", fs, files, nil)
+
+	for _, c := range []struct {
+		name  string
+		files []*ast.File
+		want  map[string]int
+		cats  []Category
+		objs  []string
+	}{
+		{
+			name:  "all",
+			files: files,
+			want:  map[string]int{"A": 2, "B": 2, "C": 2},
+			cats:  []Category{BadDecl},
+			objs:  []string{""},
+		},
+		{
+			//constants are classified by value,
+			//so declarations missing from the files are not a problem
+			name:  "missing",
+			files: files[1:2],
+			want:  map[string]int{"A": 2, "B": 2, "C": 2},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ts, err := InPackage(fs, c.files, pkg)
+			if got := summary(ts); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+			if c.cats == nil {
+				if err != nil {
+					t.Fatalf("got %v, want no errors", err)
+				}
+				return
+			}
+			es, ok := err.(Errors)
+			if !ok {
+				t.Fatalf("got %v, want Errors", err)
+			}
+			var cats []Category
+			var objs []string
+			for _, e := range es {
+				cats = append(cats, e.Category)
+				nm := ""
+				if e.Object != nil {
+					nm = e.Object.Name()
+				}
+				objs = append(objs, nm)
+				if !e.Pos.IsValid() {
+					t.Errorf("%s has no position", e.Msg)
+				}
+			}
+			if !reflect.DeepEqual(cats, c.cats) || !reflect.DeepEqual(objs, c.objs) {
+				t.Errorf("got %v %q, want %v %q\n%v", cats, objs, c.cats, c.objs, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package closed
+
+import (
+	"fmt"
+	"go/types"
+)
+
+//An Explanation is the reason that a type was, or was not, found to be closed.
+type Explanation struct {
+	//Type is the defined type explained.
+	Type *types.TypeName
+	//Closed is true if Type is one of the closed types found.
+	Closed bool
+	//Reason the heuristics accepted or rejected Type.
+	Reason string
+}
+
+func (e Explanation) String() string {
+	if e.Closed {
+		return fmt.Sprintf("%s is closed: %s", e.Type.Name(), e.Reason)
+	}
+	return fmt.Sprintf("%s is not closed: %s", e.Type.Name(), e.Reason)
+}
+
+//explainer records the first reason given for the decision about each type.
+//
+//A nil *explainer records nothing, so that the heuristics
+//may explain themselves unconditionally.
+type explainer struct {
+	reasons map[*types.TypeName]string
+}
+
+func newExplainer() *explainer {
+	return &explainer{
+		reasons: map[*types.TypeName]string{},
+	}
+}
+
+func (x *explainer) why(t *types.TypeName, format string, args ...interface{}) {
+	if x == nil {
+		return
+	}
+	if _, ok := x.reasons[t]; ok {
+		return
+	}
+	x.reasons[t] = fmt.Sprintf(format, args...)
+}
+
+//exclude t, which would be closed if its kind k were recognized.
+func (x *explainer) exclude(t *types.TypeName, k Kind) {
+	if x == nil {
+		return
+	}
+	msg := fmt.Sprintf("%s is not one of the kinds recognized", k)
+	if reason := x.reasons[t]; reason != "" {
+		msg = reason + ", but " + msg
+	}
+	x.reasons[t] = msg
+}
+
+//all gives the same reason for each of ts.
+func (x *explainer) all(ts []Type, format string, args ...interface{}) {
+	for _, t := range ts {
+		if t != nil {
+			x.why(t.Types()[0], format, args...)
+		}
+	}
+}
+
+//explanations of each of defined, the defined types of a package in order,
+//given that out are the closed types found in it.
+func (x *explainer) explanations(defined []*types.TypeName, out []Type) []Explanation {
+	if x == nil {
+		return nil
+	}
+	closed := map[*types.TypeName]bool{}
+	for _, t := range out {
+		closed[t.Types()[0]] = true
+	}
+
+	acc := make([]Explanation, 0, len(defined))
+	for _, t := range defined {
+		reason, ok := x.reasons[t]
+		if !ok {
+			reason = unexplained(t)
+		}
+		acc = append(acc, Explanation{
+			Type:   t,
+			Closed: closed[t],
+			Reason: reason,
+		})
+	}
+	return acc
+}
+
+//unexplained gives the reason for rejecting a type that no heuristic considered.
+func unexplained(t *types.TypeName) string {
+	switch u := t.Type().Underlying().(type) {
+	case *types.Basic:
+		return fmt.Sprintf("no constants of type %s are declared in the package", t.Name())
+	case *types.Struct:
+		return "structs are not closed, except for the Null types of database/sql"
+	case *types.Interface:
+		return "interface was not considered"
+	default:
+		return fmt.Sprintf("its underlying type %s cannot be closed", types.TypeString(u, types.RelativeTo(t.Pkg())))
+	}
+}
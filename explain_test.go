@@ -0,0 +1,124 @@
+package closed
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	const src = `package p
+
+type Sentinel int
+
+const S Sentinel = 1
+
+type Enum int
+
+const (
+	E0 Enum = iota
+	E1
+)
+
+type Flags uint
+
+const (
+	F0 Flags = 1 << iota
+	F1
+	F2
+	F3
+	F01 = F0 | F1
+)
+
+type Mask uint
+
+const (
+	M0 Mask = 1 << iota
+	M1
+	M2
+	MAll Mask = 0xff | M0
+)
+
+type Str string
+
+type Open interface {
+	M()
+}
+
+type Lonely interface {
+	m()
+}
+
+type Sum interface {
+	isSum()
+}
+
+type A struct{}
+
+func (A) isSum() {}
+
+type B struct{}
+
+func (B) isSum() {}
+
+type Fn func()
+`
+	l := check(t, src)
+	ts, es, err := Explain(l.fs, l.files, l.pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) == 0 {
+		t.Fatal("no closed types")
+	}
+
+	want := []struct {
+		name   string
+		closed bool
+		reason string
+	}{
+		{"Sentinel", false, "sentinel"},
+		{"Enum", true, "integer enum"},
+		{"Flags", true, "bitset with 5 values"},
+		{"Mask", false, "has bits not in any single bit value"},
+		{"Str", false, "no constants"},
+		{"Open", false, "no unexported methods"},
+		{"Lonely", false, "no types in the package satisfy it"},
+		{"Sum", true, "tag methods isSum"},
+		{"A", false, "structs are not closed"},
+		{"B", false, "structs are not closed"},
+		{"Fn", false, "cannot be closed"},
+	}
+	if len(es) != len(want) {
+		t.Fatalf("got %d explanations, want %d: %v", len(es), len(want), es)
+	}
+	for i, w := range want {
+		e := es[i]
+		if e.Type.Name() != w.name || e.Closed != w.closed || !strings.Contains(e.Reason, w.reason) {
+			t.Errorf("got %s, want %s with closed = %v because %q", e, w.name, w.closed, w.reason)
+		}
+	}
+}
+
+func TestExplainExclude(t *testing.T) {
+	l := check(t, "package p\n\ntype E int\n\nconst (\n\tE0 E = iota\n\tE1\n)\n\ntype F int\n")
+	E := l.pkg.Scope().Lookup("E").(*types.TypeName)
+	F := l.pkg.Scope().Lookup("F").(*types.TypeName)
+
+	cfg := &Config{Kinds: KindInterface}
+	_, es, err := cfg.Explain(l.fs, l.files, l.pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "integer enum with 2 constants, not a bitset as they are declared with iota and no bit operations, but enum is not one of the kinds recognized"
+	if es[0].Type != E || es[0].Closed || es[0].Reason != want {
+		t.Errorf("got %s, want %s is not closed: %s", es[0], E.Name(), want)
+	}
+
+	//with no reason to be closed, there is nothing to contradict
+	x := newExplainer()
+	x.exclude(F, KindEnum)
+	if got, want := x.reasons[F], "enum is not one of the kinds recognized"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
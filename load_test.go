@@ -0,0 +1,98 @@
+package closed
+
+import (
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+//A loaded package for tests and benchmarks.
+type loaded struct {
+	fs    *token.FileSet
+	files []*ast.File
+	pkg   *types.Package
+}
+
+//stdPackages is a large module's worth of packages
+//with many closed types and types in common.
+var stdPackages = []string{
+	"go/ast",
+	"go/constant",
+	"go/token",
+	"go/types",
+	"go/parser",
+	"text/template/parse",
+	"html/template",
+	"reflect",
+	"encoding/json",
+	"encoding/xml",
+	"database/sql",
+	"go/printer",
+	"go/doc",
+	"archive/tar",
+	"image/png",
+	"compress/flate",
+	"math/big",
+	"time",
+	"os",
+	"syscall",
+	"debug/elf",
+	"debug/dwarf",
+	"regexp/syntax",
+}
+
+var (
+	stdOnce   sync.Once
+	stdLoaded []loaded
+	stdErr    error
+)
+
+//loadStd type checks stdPackages from source, once.
+func loadStd(tb testing.TB) []loaded {
+	tb.Helper()
+	stdOnce.Do(func() {
+		fs := token.NewFileSet()
+		imp := importer.ForCompiler(fs, "source", nil)
+		for _, path := range stdPackages {
+			var l loaded
+			l, stdErr = load(fs, imp, path)
+			if stdErr != nil {
+				return
+			}
+			stdLoaded = append(stdLoaded, l)
+		}
+	})
+	if stdErr != nil {
+		tb.Fatal(stdErr)
+	}
+	return stdLoaded
+}
+
+func load(fs *token.FileSet, imp types.Importer, path string) (loaded, error) {
+	bi, err := build.Import(path, "", 0)
+	if err != nil {
+		return loaded{}, err
+	}
+	var files []*ast.File
+	for _, nm := range bi.GoFiles {
+		f, err := parser.ParseFile(fs, filepath.Join(bi.Dir, nm), nil, parser.ParseComments)
+		if err != nil {
+			return loaded{}, err
+		}
+		files = append(files, f)
+	}
+	cfg := types.Config{
+		Importer: imp,
+	}
+	pkg, err := cfg.Check(path, fs, files, nil)
+	if err != nil {
+		return loaded{}, err
+	}
+	return loaded{fs, files, pkg}, nil
+}
@@ -1,31 +1,67 @@
 package closed
 
 import (
+	"container/list"
 	"go/token"
 	"go/types"
 	"sync"
 )
 
+//methodSetCache remembers the methodSets of types.
+//If limit > 0, it holds at most limit entries,
+//evicting the least recently used.
 type methodSetCache struct {
-	mu *sync.Mutex
-	m  map[*types.TypeName]methodSets
+	mu    sync.Mutex
+	limit int
+	m     map[*types.TypeName]*list.Element
+	//lru holds *methodSetEntry, most recently used first.
+	lru *list.List
 }
 
-var cache = &methodSetCache{
-	mu: new(sync.Mutex),
-	m:  map[*types.TypeName]methodSets{},
+type methodSetEntry struct {
+	t  *types.TypeName
+	ms methodSets
+}
+
+func newMethodSetCache(limit int) *methodSetCache {
+	return &methodSetCache{
+		limit: limit,
+		m:     map[*types.TypeName]*list.Element{},
+		lru:   list.New(),
+	}
 }
 
 func (m *methodSetCache) get(t *types.TypeName) methodSets {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	return m.m[t]
+	e, ok := m.m[t]
+	if !ok {
+		return methodSets{}
+	}
+	m.lru.MoveToFront(e)
+	return e.Value.(*methodSetEntry).ms
 }
 
 func (m *methodSetCache) put(t *types.TypeName, ms methodSets) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.m[t] = ms
+	if e, ok := m.m[t]; ok {
+		e.Value.(*methodSetEntry).ms = ms
+		m.lru.MoveToFront(e)
+		return
+	}
+	m.m[t] = m.lru.PushFront(&methodSetEntry{t, ms})
+	if m.limit > 0 && m.lru.Len() > m.limit {
+		last := m.lru.Back()
+		m.lru.Remove(last)
+		delete(m.m, last.Value.(*methodSetEntry).t)
+	}
+}
+
+func (m *methodSetCache) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
 }
 
 type name struct {
@@ -111,8 +147,8 @@ type methodSets struct {
 	T, ptrT methodSet
 }
 
-func methodSetsOf(t *types.TypeName) methodSets {
-	if ms := cache.get(t); ms.T != nil {
+func (m *methodSetCache) of(t *types.TypeName) methodSets {
+	if ms := m.get(t); ms.T != nil {
 		return ms
 	}
 
@@ -127,6 +163,6 @@ func methodSetsOf(t *types.TypeName) methodSets {
 		ms.ptrT = computeMethodSet(types.NewPointer(T))
 	}
 
-	cache.put(t, ms)
+	m.put(t, ms)
 	return ms
 }
@@ -0,0 +1,374 @@
+package closed
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+//Portable is a serializable description of a Type.
+//
+//It refers to types and constants by name instead of by identity,
+//so a Type found when type checking a package from source
+//can be recreated from a different view of the same package,
+//such as one loaded from export data.
+type Portable struct {
+	//Kind is the name of the Type: Enum, Bitset, Interface, EmptySum, or OptionalStruct.
+	Kind string
+	//Types are the names of the defined type and its aliases.
+	Types []string
+
+	//NonZero is Enum.NonZero.
+	NonZero bool
+	//Ordered is Enum.Ordered.
+	Ordered bool
+	//LabeledZero is true if a label of an Enum has the zero value of its type.
+	//A bool Enum is always taken to include false.
+	//It is not needed to Resolve p, but lets p be described
+	//without the values of the labels.
+	LabeledZero bool
+	//NonNil is Interface.NonNil.
+	NonNil bool
+	//Nil is EmptySum.Nil.
+	Nil bool
+
+	//Labels are the Enum.Labels or Bitset.Flags.
+	Labels [][]string
+	//OrFlags are the Bitset.OrFlags.
+	OrFlags [][]string
+
+	//Members are the Interface.Members or EmptySum.Members.
+	Members []PortableType
+	//FalseMembers are the Interface.FalseMembers.
+	FalseMembers []PortableType
+	//TagMethods are the Interface.TagMethods.
+	TagMethods []string
+
+	//Discriminant and Field are the names of the fields of an OptionalStruct.
+	Discriminant, Field string
+}
+
+//PortableType is a serializable description of a member of a sum.
+type PortableType struct {
+	//Path is the import path of the package defining the type
+	//or empty for predeclared types.
+	Path string
+	//Names of the type.
+	//Names[0] is the defined type and Names[1:] are its aliases.
+	Names []string
+	//Ptr is true if the member is a pointer to the named type.
+	Ptr bool
+}
+
+//ToPortable creates a Portable description of t.
+func ToPortable(t Type) (*Portable, error) {
+	p := &Portable{
+		Types: typeNames(t.Types()),
+	}
+	switch t := t.(type) {
+	case *Enum:
+		p.Kind = "Enum"
+		p.NonZero = t.NonZero
+		p.Ordered = t.Ordered
+		p.LabeledZero = labeledZero(t)
+		p.Labels = constNames(t.Labels)
+
+	case *Bitset:
+		p.Kind = "Bitset"
+		p.Labels = constNames(t.Flags)
+		p.OrFlags = constNames(t.OrFlags)
+
+	case *Interface:
+		p.Kind = "Interface"
+		p.NonNil = t.NonNil
+		p.Members = portableMembers(t.Members)
+		p.FalseMembers = portableMembers(t.FalseMembers)
+		p.TagMethods = t.TagMethods
+
+	case *EmptySum:
+		p.Kind = "EmptySum"
+		p.Nil = t.Nil
+		for _, m := range t.Members {
+			pt, err := portableTypeOf(m)
+			if err != nil {
+				return nil, err
+			}
+			p.Members = append(p.Members, pt)
+		}
+
+	case *OptionalStruct:
+		p.Kind = "OptionalStruct"
+		p.Discriminant = t.Discriminant.Name()
+		p.Field = t.Field.Name()
+
+	default:
+		return nil, fmt.Errorf("unknown closed type %T", t)
+	}
+	return p, nil
+}
+
+//labeledZero reports whether a label of e has the zero value of its type.
+func labeledZero(e *Enum) bool {
+	for _, L := range e.Labels {
+		var z constant.Value
+		switch L[0].Val().Kind() {
+		case constant.Bool:
+			return true
+		case constant.String:
+			z = constant.MakeString("")
+		default:
+			z = constant.MakeInt64(0)
+		}
+		if constant.Compare(L[0].Val(), token.EQL, z) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeNames(ts []*types.TypeName) []string {
+	acc := make([]string, len(ts))
+	for i, t := range ts {
+		acc[i] = t.Name()
+	}
+	return acc
+}
+
+func constNames(css [][]*types.Const) [][]string {
+	acc := make([][]string, len(css))
+	for i, cs := range css {
+		acc[i] = make([]string, len(cs))
+		for j, c := range cs {
+			acc[i][j] = c.Name()
+		}
+	}
+	return acc
+}
+
+func portableMembers(ms []*TypeNamesAndType) []PortableType {
+	acc := make([]PortableType, len(ms))
+	for i, m := range ms {
+		_, isPtr := m.Type.(*types.Pointer)
+		acc[i] = PortableType{
+			Path:  m.TypeName[0].Pkg().Path(),
+			Names: typeNames(m.TypeName),
+			Ptr:   isPtr,
+		}
+	}
+	return acc
+}
+
+func portableTypeOf(t types.Type) (PortableType, error) {
+	var pt PortableType
+	if p, ok := t.(*types.Pointer); ok {
+		pt.Ptr = true
+		t = p.Elem()
+	}
+	switch t := t.(type) {
+	case *types.Named:
+		o := t.Obj()
+		if o.Pkg() != nil {
+			pt.Path = o.Pkg().Path()
+		}
+		pt.Names = []string{o.Name()}
+	case *types.Basic:
+		pt.Names = []string{t.Name()}
+	default:
+		return pt, fmt.Errorf("cannot describe member type %s", t)
+	}
+	return pt, nil
+}
+
+//Resolve recreates the Type described by p
+//using the objects of pkg, the package that defines it.
+//
+//Any members defined in other packages must be imported,
+//directly or indirectly, by pkg.
+func (p *Portable) Resolve(pkg *types.Package) (Type, error) {
+	r := &resolver{pkg: pkg}
+	typs := r.typeNames(pkg, p.Types)
+
+	var t Type
+	switch p.Kind {
+	case "Enum":
+		t = &Enum{
+			typs:    typs,
+			NonZero: p.NonZero,
+			Ordered: p.Ordered,
+			Labels:  r.consts(p.Labels),
+		}
+
+	case "Bitset":
+		t = &Bitset{
+			typs:    typs,
+			Flags:   r.consts(p.Labels),
+			OrFlags: r.consts(p.OrFlags),
+		}
+
+	case "Interface":
+		t = &Interface{
+			typs:         typs,
+			NonNil:       p.NonNil,
+			Members:      r.members(p.Members),
+			FalseMembers: r.members(p.FalseMembers),
+			TagMethods:   p.TagMethods,
+		}
+
+	case "EmptySum":
+		ms := make([]types.Type, len(p.Members))
+		for i, m := range p.Members {
+			ms[i] = r.member(m).Type
+		}
+		t = &EmptySum{
+			typs:    typs,
+			Nil:     p.Nil,
+			Members: ms,
+		}
+
+	case "OptionalStruct":
+		o := &OptionalStruct{
+			typs: typs,
+		}
+		if r.err == nil {
+			if s, ok := typs[0].Type().Underlying().(*types.Struct); ok {
+				o.Discriminant = r.field(s, p.Discriminant)
+				o.Field = r.field(s, p.Field)
+			} else {
+				r.fail("%s is not a struct", typs[0].Name())
+			}
+		}
+		t = o
+
+	default:
+		return nil, fmt.Errorf("unknown closed type kind %q", p.Kind)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return t, nil
+}
+
+//resolver looks up names, recording the first failure.
+type resolver struct {
+	pkg *types.Package
+	err error
+}
+
+func (r *resolver) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (r *resolver) lookup(pkg *types.Package, nm string) types.Object {
+	var o types.Object
+	if pkg == nil {
+		o = types.Universe.Lookup(nm)
+	} else {
+		o = pkg.Scope().Lookup(nm)
+	}
+	if o == nil {
+		r.fail("could not find %s in %s", nm, pkg)
+	}
+	return o
+}
+
+func (r *resolver) typeNames(pkg *types.Package, nms []string) []*types.TypeName {
+	acc := make([]*types.TypeName, 0, len(nms))
+	for _, nm := range nms {
+		t, ok := r.lookup(pkg, nm).(*types.TypeName)
+		if !ok {
+			r.fail("%s in %s is not a type", nm, pkg)
+			return nil
+		}
+		acc = append(acc, t)
+	}
+	return acc
+}
+
+func (r *resolver) consts(nmss [][]string) [][]*types.Const {
+	acc := make([][]*types.Const, len(nmss))
+	for i, nms := range nmss {
+		for _, nm := range nms {
+			c, ok := r.lookup(r.pkg, nm).(*types.Const)
+			if !ok {
+				r.fail("%s in %s is not a constant", nm, r.pkg)
+				continue
+			}
+			acc[i] = append(acc[i], c)
+		}
+	}
+	return acc
+}
+
+//importOf finds the package with path among pkg and its transitive imports.
+func (r *resolver) importOf(path string) *types.Package {
+	if path == "" {
+		return nil
+	}
+	seen := map[*types.Package]bool{}
+	var find func(*types.Package) *types.Package
+	find = func(p *types.Package) *types.Package {
+		if seen[p] {
+			return nil
+		}
+		seen[p] = true
+		if p.Path() == path {
+			return p
+		}
+		for _, imp := range p.Imports() {
+			if q := find(imp); q != nil {
+				return q
+			}
+		}
+		return nil
+	}
+	p := find(r.pkg)
+	if p == nil {
+		r.fail("%s does not import %q", r.pkg.Path(), path)
+	}
+	return p
+}
+
+func (r *resolver) member(m PortableType) *TypeNamesAndType {
+	pkg := r.importOf(m.Path)
+	if r.err != nil {
+		return &TypeNamesAndType{}
+	}
+	ts := r.typeNames(pkg, m.Names)
+	if len(ts) == 0 {
+		r.fail("member of %s has no name", r.pkg.Path())
+		return &TypeNamesAndType{}
+	}
+	T := ts[0].Type()
+	if m.Ptr {
+		T = types.NewPointer(T)
+	}
+	return &TypeNamesAndType{
+		TypeName: ts,
+		Type:     T,
+	}
+}
+
+func (r *resolver) members(ms []PortableType) []*TypeNamesAndType {
+	if len(ms) == 0 {
+		return nil
+	}
+	acc := make([]*TypeNamesAndType, len(ms))
+	for i, m := range ms {
+		acc[i] = r.member(m)
+	}
+	return acc
+}
+
+func (r *resolver) field(s *types.Struct, nm string) *types.Var {
+	for i := 0; i < s.NumFields(); i++ {
+		if f := s.Field(i); f.Name() == nm {
+			return f
+		}
+	}
+	r.fail("no field %s", nm)
+	return nil
+}
@@ -16,10 +16,24 @@ func stdDatabaseSql(potOpts []*types.TypeName) []Type {
 
 		T := t.Type().Underlying().(*types.Struct)
 
+		//the discriminant is the last bool, Valid,
+		//and the field is the first of the rest
+		d := -1
+		for i := T.NumFields() - 1; i >= 0; i-- {
+			if b, ok := T.Field(i).Type().(*types.Basic); ok && b.Kind() == types.Bool {
+				d = i
+				break
+			}
+		}
+		f := 0
+		if d == 0 {
+			f = 1
+		}
+
 		acc = append(acc, &OptionalStruct{
 			typs:         []*types.TypeName{t},
-			Discriminant: T.Field(1),
-			Field:        T.Field(0),
+			Discriminant: T.Field(d),
+			Field:        T.Field(f),
 		})
 	}
 	return acc
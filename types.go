@@ -42,6 +42,8 @@ func findAliasesAndRegular(ts []*types.TypeName) (map[string][]*types.TypeName,
 }
 
 //potentiallyClosedStructs returns structs with at least two fields where the first is integral or boolean.
+//
+//Optionals have exactly fields fields and one is a bool.
 func potentiallyClosedStructs(ts []*types.TypeName) (optionals, unions []*types.TypeName) {
 outer:
 	for _, t := range ts {
@@ -109,13 +111,15 @@ func transClosureAliasesRec(aliases map[string][]*types.TypeName, t *types.TypeN
 
 //binInterfaces into defined empty ifaces and ifaces with at least one unexported
 //method from the same package as its definition.
-func binInterfaces(abstract []*types.TypeName) (empty, closed []*types.TypeName) {
+func binInterfaces(cache *methodSetCache, why *explainer, abstract []*types.TypeName) (empty, closed []*types.TypeName) {
 	for _, i := range abstract {
-		ims := methodSetsOf(i).T
+		ims := cache.of(i).T
 		if len(ims) == 0 {
 			empty = append(empty, i)
 		} else if ims.HasUnexported(i.Pkg().String()) {
 			closed = append(closed, i)
+		} else {
+			why.why(i, "interface has no unexported methods, so it may be satisfied by types in any package")
 		}
 	}
 	return
@@ -135,18 +139,36 @@ func (t typeOrPtr) Type() types.Type {
 }
 
 //satisfiers of abstract among concrete.
-func satisfiers(abstract []*types.TypeName, concrete []*types.TypeName) map[*types.TypeName][]typeOrPtr {
+//
+//Every interface in abstract has an unexported method,
+//so only the concrete types with the least common of those methods are checked.
+func satisfiers(cache *methodSetCache, abstract []*types.TypeName, concrete []*types.TypeName) map[*types.TypeName][]typeOrPtr {
 	m := map[*types.TypeName][]typeOrPtr{}
+	if len(abstract) == 0 {
+		return m
+	}
+
+	idx := indexUnexportedMethods(cache, concrete)
 	for _, i := range abstract {
-		ims := methodSetsOf(i).T
-		for _, c := range concrete {
-			ms := methodSetsOf(c)
-			if ms.T.Satisfies(ims) {
-				m[i] = append(m[i], typeOrPtr{TypeName: c})
-			} else if ms.ptrT != nil && ms.ptrT.Satisfies(ims) {
+		ims := cache.of(i).T
+		var cands []candidate
+		first := true
+		for n := range ims {
+			if n.pkg == "" {
+				continue
+			}
+			if cs := idx[n]; first || len(cs) < len(cands) {
+				cands, first = cs, false
+			}
+		}
+
+		for _, c := range cands {
+			if c.ms.T.Satisfies(ims) {
+				m[i] = append(m[i], typeOrPtr{TypeName: c.t})
+			} else if c.ms.ptrT != nil && c.ms.ptrT.Satisfies(ims) {
 				m[i] = append(m[i], typeOrPtr{
 					isPtr:    true,
-					TypeName: c,
+					TypeName: c.t,
 				})
 			}
 		}
@@ -154,12 +176,38 @@ func satisfiers(abstract []*types.TypeName, concrete []*types.TypeName) map[*typ
 	return m
 }
 
+//candidate is a concrete type that may satisfy a closed interface.
+type candidate struct {
+	t  *types.TypeName
+	ms methodSets
+}
+
+//indexUnexportedMethods maps each unexported method of the concrete types, or their pointers,
+//to the types that have it, in the order of concrete.
+func indexUnexportedMethods(cache *methodSetCache, concrete []*types.TypeName) map[name][]candidate {
+	idx := map[name][]candidate{}
+	for _, c := range concrete {
+		ms := cache.of(c)
+		//the method set of *T contains that of T
+		s := ms.ptrT
+		if s == nil {
+			s = ms.T
+		}
+		for n := range s {
+			if n.pkg != "" {
+				idx[n] = append(idx[n], candidate{c, ms})
+			}
+		}
+	}
+	return idx
+}
+
 //findTagMethods searches members for nullary unexported methods defined on sum
 //that have empty bodies in all members and returns that subset
 //(likely len 0 or 1)
-func findTagMethods(decls []decl, sum *types.TypeName, members []typeOrPtr) methodSet {
+func findTagMethods(cache *methodSetCache, decls []decl, sum *types.TypeName, members []typeOrPtr) methodSet {
 	cands := methodSet{}
-	for n, sig := range methodSetsOf(sum).T {
+	for n, sig := range cache.of(sum).T {
 		if n.pkg != sum.Pkg().String() {
 			continue
 		}
@@ -173,7 +221,7 @@ func findTagMethods(decls []decl, sum *types.TypeName, members []typeOrPtr) meth
 	}
 
 	for _, m := range members {
-		ms := methodSetsOf(m.TypeName)
+		ms := cache.of(m.TypeName)
 		s := ms.T
 		if m.isPtr {
 			s = ms.ptrT
@@ -217,20 +265,24 @@ func anyExported(ts []*types.TypeName) bool {
 var sizer = types.SizesFor("gc", "amd64")
 
 func zeroSized(t types.Type) bool {
+	//the size of a generic type depends on its type arguments
+	if n, ok := t.(*types.Named); ok && n.TypeParams().Len() > 0 {
+		return false
+	}
 	return sizer.Sizeof(t) == 0
 }
 
 //pkgIfaces packages interfaces into Interface and InterfaceSum.
-func pkgIfaces(aliases map[string][]*types.TypeName, decls []decl, ifaces map[*types.TypeName][]typeOrPtr) []Type {
+func pkgIfaces(cache *methodSetCache, aliases map[string][]*types.TypeName, decls []decl, ifaces map[*types.TypeName][]typeOrPtr) []Type {
 	acc := make([]Type, 0, len(ifaces))
 	for t, ms := range ifaces {
 		names := transClosureAliases(aliases, t)
 
-		tags := findTagMethods(decls, t, ms)
+		tags := findTagMethods(cache, decls, t, ms)
 		if len(tags) == 0 {
 			acc = append(acc, pkgClosedInterface(aliases, names, t, ms))
 		} else {
-			acc = append(acc, pkgInterfaceSum(aliases, names, t, ms, tags))
+			acc = append(acc, pkgInterfaceSum(cache, aliases, names, t, ms, tags))
 		}
 	}
 	return acc
@@ -254,8 +306,8 @@ func pkgClosedInterface(aliases map[string][]*types.TypeName, names []*types.Typ
 	}
 }
 
-func pkgInterfaceSum(aliases map[string][]*types.TypeName, names []*types.TypeName, t *types.TypeName, ms []typeOrPtr, tags methodSet) *Interface {
-	real, fake := splitIfaceSumMethods(aliases, names, t, ms, tags)
+func pkgInterfaceSum(cache *methodSetCache, aliases map[string][]*types.TypeName, names []*types.TypeName, t *types.TypeName, ms []typeOrPtr, tags methodSet) *Interface {
+	real, fake := splitIfaceSumMethods(cache, aliases, names, t, ms, tags)
 	tagNames := pkgTagMethods(tags)
 	return &Interface{
 		typs:         names,
@@ -265,8 +317,8 @@ func pkgInterfaceSum(aliases map[string][]*types.TypeName, names []*types.TypeNa
 	}
 }
 
-func splitIfaceSumMethods(aliases map[string][]*types.TypeName, names []*types.TypeName, t *types.TypeName, ms []typeOrPtr, tags methodSet) (real, fake []*TypeNamesAndType) {
-	checkFalse := mustCheckForFalseMembers(names, t, tags)
+func splitIfaceSumMethods(cache *methodSetCache, aliases map[string][]*types.TypeName, names []*types.TypeName, t *types.TypeName, ms []typeOrPtr, tags methodSet) (real, fake []*TypeNamesAndType) {
+	checkFalse := mustCheckForFalseMembers(cache, names, t, tags)
 	var embeddings map[*types.TypeName][]types.Type
 
 	typs := make([]*TypeNamesAndType, 0, len(ms))
@@ -275,22 +327,25 @@ func splitIfaceSumMethods(aliases map[string][]*types.TypeName, names []*types.T
 	for _, m := range ms {
 		T := pkgM(aliases, m)
 
-		if checkFalse && maybeFalse(T, tags) && isEmbedded(embeddings, T, ms) {
-			falseTyps = append(falseTyps, T)
-		} else {
-			typs = append(typs, T)
+		if checkFalse && maybeFalse(cache, T, tags) {
+			embeddings = lazyComputeEmbeddingsOfMembers(embeddings, ms)
+			if isEmbedded(embeddings, T) {
+				falseTyps = append(falseTyps, T)
+				continue
+			}
 		}
+		typs = append(typs, T)
 	}
 	return typs, falseTyps
 }
 
-func mustCheckForFalseMembers(names []*types.TypeName, t *types.TypeName, tags methodSet) bool {
-	return anyExported(names) && len(methodSetsOf(t).T) == len(tags)
+func mustCheckForFalseMembers(cache *methodSetCache, names []*types.TypeName, t *types.TypeName, tags methodSet) bool {
+	return anyExported(names) && len(cache.of(t).T) == len(tags)
 }
 
-func maybeFalse(T *TypeNamesAndType, tags methodSet) bool {
+func maybeFalse(cache *methodSetCache, T *TypeNamesAndType, tags methodSet) bool {
 	if zeroSized(T.TypeName[0].Type()) && !anyExported(T.TypeName) {
-		methods := methodSetsOf(T.TypeName[0])
+		methods := cache.of(T.TypeName[0])
 		if lt := len(tags); len(methods.T) == lt || len(methods.ptrT) == lt {
 			return true
 		}
@@ -303,11 +358,12 @@ func lazyComputeEmbeddingsOfMembers(em map[*types.TypeName][]types.Type, ms []ty
 	if em != nil {
 		return em
 	}
+	em = map[*types.TypeName][]types.Type{}
 
 	for _, m := range ms {
 		T := m.TypeName
 
-		s, ok := T.Type().(*types.Struct)
+		s, ok := T.Type().Underlying().(*types.Struct)
 		if !ok || zeroSized(s) {
 			continue
 		}
@@ -324,8 +380,7 @@ func lazyComputeEmbeddingsOfMembers(em map[*types.TypeName][]types.Type, ms []ty
 	return em
 }
 
-func isEmbedded(ems map[*types.TypeName][]types.Type, t *TypeNamesAndType, ms []typeOrPtr) bool {
-	ems = lazyComputeEmbeddingsOfMembers(ems, ms)
+func isEmbedded(ems map[*types.TypeName][]types.Type, t *TypeNamesAndType) bool {
 	for _, ets := range ems {
 		for _, ef := range ets {
 			if types.Identical(t.Type, ef) {
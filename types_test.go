@@ -0,0 +1,260 @@
+package closed
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+//genAST generates a package shaped like an AST package or protobuf output:
+//the sums Node, Expr, and Stmt, with n members split between Expr and Stmt,
+//and n messages, each with a oneof: a sum with two members,
+//as generated by protoc-gen-go.
+func genAST(n int) string {
+	var b bytes.Buffer
+	b.WriteString(`package ast
+
+type Node interface {
+	Pos() int
+	End() int
+	node()
+}
+
+type Expr interface {
+	Node
+	expr()
+}
+
+type Stmt interface {
+	Node
+	stmt()
+}
+
+type Message interface {
+	Reset()
+	String() string
+	message()
+}
+`)
+	for i := 0; i < n; i++ {
+		kind := "expr"
+		if i%2 == 1 {
+			kind = "stmt"
+		}
+		fmt.Fprintf(&b, `
+type N%[1]d struct{ p, e int }
+
+func (n *N%[1]d) Pos() int { return n.p }
+func (n *N%[1]d) End() int { return n.e }
+func (*N%[1]d) node()      {}
+func (*N%[1]d) %[2]s()     {}
+
+type M%[1]d struct{ s string }
+
+func (m *M%[1]d) Reset()         { m.s = "" }
+func (m *M%[1]d) String() string { return m.s }
+func (m *M%[1]d) Pos() int       { return 0 }
+func (m *M%[1]d) End() int       { return 0 }
+func (*M%[1]d) message()         {}
+
+type isM%[1]d_Kind interface {
+	isM%[1]d_Kind()
+}
+
+type M%[1]d_A struct{ A int }
+type M%[1]d_B struct{ B string }
+
+func (*M%[1]d_A) isM%[1]d_Kind() {}
+func (*M%[1]d_B) isM%[1]d_Kind() {}
+`, i, kind)
+	}
+	return b.String()
+}
+
+func check(tb testing.TB, src string) loaded {
+	tb.Helper()
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, "gen.go", src, parser.ParseComments)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	files := []*ast.File{f}
+	var cfg types.Config
+	pkg, err := cfg.Check("gen", fs, files, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return loaded{fs, files, pkg}
+}
+
+//closedAndConcrete finds the arguments to satisfiers in l.
+func closedAndConcrete(cache *methodSetCache, l loaded) (abstract, concrete []*types.TypeName) {
+	_, allTypes := extract(l.fs, l.pkg.Scope())
+	_, regTypes := findAliasesAndRegular(allTypes)
+	abstract, concrete = interfacesAndConcrete(regTypes)
+	_, abstract = binInterfaces(cache, nil, abstract)
+	return abstract, concrete
+}
+
+//naiveSatisfiers checks every concrete type against every interface.
+func naiveSatisfiers(cache *methodSetCache, abstract []*types.TypeName, concrete []*types.TypeName) map[*types.TypeName][]typeOrPtr {
+	m := map[*types.TypeName][]typeOrPtr{}
+	for _, i := range abstract {
+		ims := cache.of(i).T
+		for _, c := range concrete {
+			ms := cache.of(c)
+			if ms.T.Satisfies(ims) {
+				m[i] = append(m[i], typeOrPtr{TypeName: c})
+			} else if ms.ptrT != nil && ms.ptrT.Satisfies(ims) {
+				m[i] = append(m[i], typeOrPtr{
+					isPtr:    true,
+					TypeName: c,
+				})
+			}
+		}
+	}
+	return m
+}
+
+func TestSatisfiers(t *testing.T) {
+	pkgs := append([]loaded{check(t, genAST(50))}, loadStd(t)...)
+	for _, l := range pkgs {
+		cache := newMethodSetCache(0)
+		abstract, concrete := closedAndConcrete(cache, l)
+		got := satisfiers(cache, abstract, concrete)
+		want := naiveSatisfiers(cache, abstract, concrete)
+		for _, i := range abstract {
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("%s.%s: got %d satisfiers, want %d", l.pkg.Path(), i.Name(), len(got[i]), len(want[i]))
+			}
+		}
+	}
+
+	l := pkgs[0]
+	ts, err := InPackage(l.fs, l.files, l.pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summary(ts)
+	if len(got) != 4+50 {
+		t.Errorf("got %d closed types, want %d", len(got), 4+50)
+	}
+	for nm, n := range map[string]int{"Node": 50, "Expr": 25, "Stmt": 25, "Message": 50, "isM7_Kind": 2} {
+		if got[nm] != n {
+			t.Errorf("%s has %d members, want %d", nm, got[nm], n)
+		}
+	}
+}
+
+func benchmarkSatisfiers(b *testing.B, n int, sat func(*methodSetCache, []*types.TypeName, []*types.TypeName) map[*types.TypeName][]typeOrPtr) {
+	l := check(b, genAST(n))
+	cache := newMethodSetCache(0)
+	abstract, concrete := closedAndConcrete(cache, l)
+	//only measure the search, not computing the method sets
+	sat(cache, abstract, concrete)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sat(cache, abstract, concrete)
+	}
+}
+
+func BenchmarkSatisfiers(b *testing.B) {
+	for _, n := range []int{100, 500, 2000} {
+		b.Run(fmt.Sprintf("indexed/%d", n), func(b *testing.B) {
+			benchmarkSatisfiers(b, n, satisfiers)
+		})
+		b.Run(fmt.Sprintf("naive/%d", n), func(b *testing.B) {
+			benchmarkSatisfiers(b, n, naiveSatisfiers)
+		})
+	}
+}
+
+func BenchmarkInPackageGenerated(b *testing.B) {
+	l := check(b, genAST(2000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := InPackage(l.fs, l.files, l.pkg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFalseMembers(t *testing.T) {
+	const src = `package p
+
+type Sum interface {
+	isSum()
+}
+
+type sum struct{}
+
+func (sum) isSum() {}
+
+type A struct {
+	sum
+	X int
+}
+
+type B struct {
+	*sum
+	Y string
+}
+`
+	l := check(t, src)
+	ts, err := InPackage(l.fs, l.files, l.pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 1 {
+		t.Fatalf("got %d closed types, want 1", len(ts))
+	}
+	iface := ts[0].(*Interface)
+	names := func(ms []*TypeNamesAndType) []string {
+		var acc []string
+		for _, m := range ms {
+			acc = append(acc, m.TypeName[0].Name())
+		}
+		return acc
+	}
+	if got, want := names(iface.Members), []string{"A", "B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+	if got, want := names(iface.FalseMembers), []string{"sum"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got false members %v, want %v", got, want)
+	}
+}
+
+func TestGenericSum(t *testing.T) {
+	//the size of G cannot be computed when checking for false members
+	const src = `package p
+
+type Sum[T any] interface {
+	isSum()
+}
+
+type A struct{}
+
+func (A) isSum() {}
+
+type G[T any] struct {
+	v T
+}
+
+func (G[T]) isSum() {}
+`
+	l := check(t, src)
+	ts, err := InPackage(l.fs, l.files, l.pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(ts); !reflect.DeepEqual(got, map[string]int{"Sum": 2}) {
+		t.Errorf("got %v, want Sum with 2 members", got)
+	}
+}